@@ -0,0 +1,137 @@
+package hoin
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"strings"
+
+	"github.com/joeyak/hoin-printer/escpos"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Dots per millimeter of the print head
+const DotsPerMM = escpos.DotsPerMM
+
+// BannerOptions controls how RenderBanner lays out a banner
+type BannerOptions struct {
+	// Font is used to draw the banner.  Go Bold is used when Font is nil.
+	Font *opentype.Font
+	// HeightMM is the height of the letters across the paper in millimeters.
+	// The full paper width is used when HeightMM is 0.
+	HeightMM float64
+	// Repeat is the number of times the text is printed.  Defaults to once.
+	Repeat int
+	// Separator is placed between each repeat of the text
+	Separator string
+}
+
+// RenderBanner draws text as a single line and rotates it 90 degrees
+// clockwise so it runs along the length of the paper
+//
+// The returned image is DefaultPaperWidth wide with the banner centered
+// across the paper.
+func RenderBanner(text string, opts BannerOptions) (*image.Gray, error) {
+	errMsg := "could not render banner: %w"
+
+	f := opts.Font
+	if f == nil {
+		var err error
+		f, err = opentype.Parse(gobold.TTF)
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+	}
+
+	height := DefaultPaperWidth
+	if opts.HeightMM != 0 {
+		height = int(opts.HeightMM * DotsPerMM)
+	}
+
+	err := checkRange(height, 1, DefaultPaperWidth, "height in dots")
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+
+	repeat := opts.Repeat
+	if repeat == 0 {
+		repeat = 1
+	}
+
+	err = checkRange(repeat, 1, 1000, "repeat")
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+
+	line := text + strings.Repeat(opts.Separator+text, repeat-1)
+
+	face, err := bannerFace(f, height)
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+	defer face.Close()
+
+	clusters := splitClusters(line)
+	width := measureClusters(face, clusters).Ceil()
+	if width == 0 {
+		return nil, fmt.Errorf(errMsg, errors.New("banner text is empty"))
+	}
+
+	flat := image.NewGray(image.Rect(0, 0, width, height))
+	draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+
+	d := &font.Drawer{
+		Dst:  flat,
+		Src:  image.Black,
+		Face: face,
+		Dot:  fixed.Point26_6{Y: face.Metrics().Ascent},
+	}
+	for _, c := range reorderLine(clusters, paragraphDirection(line)) {
+		drawCluster(d, c)
+	}
+
+	// Rotate clockwise and center across the paper
+	img := image.NewGray(image.Rect(0, 0, DefaultPaperWidth, width))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	offset := (DefaultPaperWidth - height) / 2
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetGray(offset+height-1-y, x, flat.GrayAt(x, y))
+		}
+	}
+
+	return img, nil
+}
+
+// bannerFace creates a face whose ascent and descent fill height dots
+func bannerFace(f *opentype.Font, height int) (font.Face, error) {
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: float64(height), DPI: 72})
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := face.Metrics()
+	face.Close()
+
+	size := float64(height) * float64(height) / float64((metrics.Ascent + metrics.Descent).Ceil())
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72})
+}
+
+// PrintBanner renders text with RenderBanner and prints it as a 24-bit image
+func (p Printer) PrintBanner(text string, opts BannerOptions) error {
+	img, err := RenderBanner(text, opts)
+	if err != nil {
+		return fmt.Errorf("could not print banner: %w", err)
+	}
+
+	err = p.PrintImage24(img, DoubleDensity)
+	if err != nil {
+		return fmt.Errorf("could not print banner: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package hoin
+
+import (
+	"fmt"
+	"sync"
+)
+
+// buffer collects commands for a buffered Printer.  It is shared by every
+// copy of the Printer.
+type buffer struct {
+	threshold int
+
+	mu   sync.Mutex
+	data []byte
+}
+
+// WithBuffering collects commands and sends them in one write instead of a
+// write per command, which is far fewer packets for a styled receipt.
+//
+// Buffered commands are sent by Flush, before anything is read from the
+// printer, before waiting on the flow control, on Close and once threshold
+// bytes are waiting.  A threshold of 0 or less never flushes on size alone.
+func WithBuffering(threshold int) Option {
+	return func(p *Printer) {
+		p.buf = &buffer{threshold: threshold}
+	}
+}
+
+func (b *buffer) write(dst func([]byte) (int, error), data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.data = append(b.data, data...)
+	if b.threshold > 0 && len(b.data) >= b.threshold {
+		err := b.flush(dst)
+		if err != nil {
+			return len(data), err
+		}
+	}
+	return len(data), nil
+}
+
+// flush writes everything waiting with dst.  Anything that couldn't be
+// written stays in the buffer.
+func (b *buffer) flush(dst func([]byte) (int, error)) error {
+	if len(b.data) == 0 {
+		return nil
+	}
+
+	n, err := dst(b.data)
+	if err != nil {
+		b.data = b.data[n:]
+		return err
+	}
+
+	b.data = b.data[:0]
+	return nil
+}
+
+// Flush sends any buffered commands to the printer.  It does nothing when
+// the Printer isn't buffered.
+func (p Printer) Flush() error {
+	if p.buf == nil {
+		return nil
+	}
+
+	p.buf.mu.Lock()
+	defer p.buf.mu.Unlock()
+
+	err := p.buf.flush(p.writeDst)
+	if err != nil {
+		return fmt.Errorf("could not flush printer: %w", err)
+	}
+	return nil
+}
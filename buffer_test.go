@@ -0,0 +1,128 @@
+package hoin_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/joeyak/hoin-printer"
+	"github.com/joeyak/hoin-printer/emulator"
+)
+
+// countingDevice counts the writes made to a Device
+type countingDevice struct {
+	*emulator.Device
+	writes int
+}
+
+func (d *countingDevice) Write(b []byte) (int, error) {
+	d.writes++
+	return d.Device.Write(b)
+}
+
+// TestGoldenBuffered makes sure buffering only changes how bytes are
+// written and not which bytes
+func TestGoldenBuffered(t *testing.T) {
+	for _, tc := range goldenCases(t) {
+		tc := tc
+		if strings.HasPrefix(tc.name, "Morse") {
+			// Morse is timed with sleeps and takes seconds to run twice
+			continue
+		}
+
+		t.Run(tc.name, func(t *testing.T) {
+			want := emulator.NewDevice()
+			wantErr := tc.run(hoin.NewPrinter(want))
+
+			device := emulator.NewDevice()
+			printer := hoin.NewPrinter(device, hoin.WithBuffering(0))
+			err := tc.run(printer)
+			if flushErr := printer.Flush(); flushErr != nil {
+				t.Fatal(flushErr)
+			}
+
+			if (err == nil) != (wantErr == nil) {
+				t.Errorf("got error %v but unbuffered got %v", err, wantErr)
+			}
+			if !bytes.Equal(device.Bytes(), want.Bytes()) {
+				t.Errorf("buffered bytes do not match unbuffered\ngot:\n%s\nwant:\n%s", listing(device.Bytes()), listing(want.Bytes()))
+			}
+		})
+	}
+}
+
+func TestBuffering(t *testing.T) {
+	device := &countingDevice{Device: emulator.NewDevice()}
+	printer := hoin.NewPrinter(device, hoin.WithBuffering(0))
+
+	printer.Initialize()
+	printer.SetBold(true)
+	printer.Justify(hoin.CenterJustify)
+	printer.Println("Receipt")
+	printer.SetBold(false)
+	if device.writes != 0 {
+		t.Fatalf("expected nothing to be written before Flush but got %d writes", device.writes)
+	}
+
+	err := printer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.writes != 1 {
+		t.Errorf("expected 1 write but got %d", device.writes)
+	}
+
+	// Status requests have to be sent before the reply can be read
+	printer.LF()
+	_, err = printer.TransmitErrorStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.writes != 2 {
+		t.Errorf("expected the status request to flush in 1 write but got %d writes", device.writes-1)
+	}
+}
+
+func TestBufferingThreshold(t *testing.T) {
+	device := &countingDevice{Device: emulator.NewDevice()}
+	printer := hoin.NewPrinter(device, hoin.WithBuffering(10))
+
+	printer.Print("12345")
+	if device.writes != 0 {
+		t.Errorf("expected nothing to be written under the threshold but got %d writes", device.writes)
+	}
+
+	printer.Print("67890")
+	if device.writes != 1 || len(device.Bytes()) != 10 {
+		t.Errorf("expected 10 bytes in 1 write at the threshold but got %d bytes in %d writes", len(device.Bytes()), device.writes)
+	}
+}
+
+func TestBufferingCloseFlushes(t *testing.T) {
+	device := emulator.NewDevice()
+	printer := hoin.NewPrinter(device, hoin.WithBuffering(0))
+
+	printer.Cut()
+	err := printer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(device.Bytes()) != 3 {
+		t.Errorf("expected Close to flush the cut but got %d bytes", len(device.Bytes()))
+	}
+}
+
+func TestBufferingFlushFailure(t *testing.T) {
+	failure := errors.New("connection reset")
+
+	device := emulator.NewDevice()
+	device.FailAt(2, failure)
+	printer := hoin.NewPrinter(device, hoin.WithBuffering(0))
+
+	printer.Cut()
+	err := printer.Flush()
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected %q to wrap %q", err, failure)
+	}
+}
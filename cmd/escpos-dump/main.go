@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alexflint/go-arg"
+	"github.com/joeyak/hoin-printer/decoder"
+)
+
+type Arguments struct {
+	Input string `arg:"positional" help:"Captured ESC/POS bytes.  STDIN is used if no filename is given or the filename is a single dash."`
+	JSON  bool   `arg:"--json" help:"Write the commands as JSON instead of a listing."`
+}
+
+func (a *Arguments) Description() string {
+	return `
+escpos-dump lists every command in a captured ESC/POS byte stream with its
+offset and decoded parameters.  Unknown and truncated commands are flagged
+with !!.
+`
+}
+
+func main() {
+	args := &Arguments{}
+	arg.MustParse(args)
+
+	err := run(args, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args *Arguments, out io.Writer) error {
+	var raw []byte
+	var err error
+	if args.Input == "" || args.Input == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(args.Input)
+	}
+	if err != nil {
+		return err
+	}
+
+	commands := decoder.Decode(raw)
+
+	if args.JSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(commands)
+	}
+
+	problems := 0
+	for _, c := range commands {
+		fmt.Fprintln(out, c)
+		if c.Error != "" {
+			problems++
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d unknown or truncated commands", problems)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joeyak/hoin-printer/decoder"
+)
+
+// capture writes raw to a file and runs escpos-dump on it
+func capture(t *testing.T, raw string, asJSON bool) (string, error) {
+	t.Helper()
+
+	input := filepath.Join(t.TempDir(), "capture.bin")
+	err := os.WriteFile(input, []byte(raw), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = run(&Arguments{Input: input, JSON: asJSON}, &out)
+	return out.String(), err
+}
+
+func TestListing(t *testing.T) {
+	out, err := capture(t, "\x1b@Total\n\x1dV\x00", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	want := []string{"ESC @", `"Total"`, "LF", "GS V 0"}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines but got %q", len(want), out)
+	}
+	for i, w := range want {
+		if !strings.Contains(lines[i], w) {
+			t.Errorf("expected %q in line %d but got %q", w, i+1, lines[i])
+		}
+	}
+}
+
+func TestListingProblems(t *testing.T) {
+	out, err := capture(t, "\x1byHi\x1bJ", false)
+	if err == nil || err.Error() != "2 unknown or truncated commands" {
+		t.Errorf("expected the problems to be counted but got %v", err)
+	}
+	if strings.Count(out, "!!") != 2 {
+		t.Errorf("expected both problems to be flagged but got %q", out)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	out, err := capture(t, "\x1ba\x01Hi\x1bJ", true)
+	if err != nil {
+		t.Fatalf("expected problems not to fail JSON output but got %v", err)
+	}
+
+	var commands []decoder.Command
+	err = json.Unmarshal([]byte(out), &commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(commands) != 3 {
+		t.Fatalf("expected 3 commands but got %d", len(commands))
+	}
+	if commands[0].Params[0].Meaning != "center" {
+		t.Errorf("expected the justification to be decoded but got %+v", commands[0])
+	}
+	if commands[1].Text != "Hi" || commands[1].Offset != 3 {
+		t.Errorf("expected text at offset 3 but got %+v", commands[1])
+	}
+	if commands[2].Error != "truncated command" || string(commands[2].Raw) != "\x1bJ" {
+		t.Errorf("expected a truncated command but got %+v", commands[2])
+	}
+}
+
+func TestMissingInput(t *testing.T) {
+	err := run(&Arguments{Input: filepath.Join(t.TempDir(), "missing.bin")}, &bytes.Buffer{})
+	if err == nil {
+		t.Error("expected a missing file to fail")
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexflint/go-arg"
+	"github.com/joeyak/hoin-printer"
+	"github.com/joeyak/hoin-printer/emulator"
+)
+
+type Arguments struct {
+	Addr string `arg:"-a,--addr" help:"Address to listen on for print jobs.  Defaults to the port of the default printer address."`
+	Out  string `arg:"-o,--out" default:"jobs" help:"Directory to write received jobs to."`
+	HTTP string `arg:"--http" help:"Address to serve an HTML gallery of received jobs on, such as :8080."`
+
+	Buffer  int     `arg:"--buffer" help:"Size of the receive buffer in bytes.  Bytes that arrive while it is full are dropped.  0 is unlimited."`
+	Speed   float64 `arg:"--speed" help:"Print speed in mm/s.  Status replies wait until the buffer is printed.  0 prints instantly."`
+	XonXoff bool    `arg:"--xonxoff" help:"Send XOFF when the buffer is three quarters full and XON once it is down to a quarter.  Needs --buffer."`
+}
+
+func (a *Arguments) Description() string {
+	return `
+hoin-emulator listens for print jobs like a HOIN network printer.  Every
+connection is one job and is saved as a PNG of the printed paper and a .bin
+capture of the raw bytes.
+`
+}
+
+// pageGap is the height in dots of the gap drawn between cut pages
+const pageGap = 16
+
+type server struct {
+	out      string
+	throttle emulator.ThrottleOptions
+
+	mu   sync.Mutex
+	jobs int
+}
+
+func main() {
+	args := &Arguments{}
+	arg.MustParse(args)
+
+	if args.Addr == "" {
+		_, port, err := net.SplitHostPort(hoin.DefaultPrinterIP)
+		if err != nil {
+			log.Fatal(err)
+		}
+		args.Addr = ":" + port
+	}
+
+	if args.XonXoff && args.Buffer <= 0 {
+		log.Fatal("--xonxoff needs a --buffer size")
+	}
+
+	err := os.MkdirAll(args.Out, 0755)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s := &server{
+		out: args.Out,
+		throttle: emulator.ThrottleOptions{
+			BufferSize: args.Buffer,
+			Speed:      args.Speed,
+			XonXoff:    args.XonXoff,
+		},
+	}
+	s.jobs, err = s.lastJob()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if args.HTTP != "" {
+		go func() {
+			log.Printf("serving gallery on %s", args.HTTP)
+			log.Fatal(http.ListenAndServe(args.HTTP, s.gallery()))
+		}()
+	}
+
+	listener, err := net.Listen("tcp", args.Addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("listening for jobs on %s", listener.Addr())
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+		go s.handle(conn)
+	}
+}
+
+// lastJob finds the highest job number already in the output directory so
+// restarts don't overwrite old jobs
+func (s *server) lastJob() (int, error) {
+	matches, err := filepath.Glob(filepath.Join(s.out, "job-*.bin"))
+	if err != nil {
+		return 0, err
+	}
+
+	last := 0
+	for _, match := range matches {
+		var n int
+		_, err := fmt.Sscanf(filepath.Base(match), "job-%d.bin", &n)
+		if err == nil && n > last {
+			last = n
+		}
+	}
+	return last, nil
+}
+
+func (s *server) nextJob() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs++
+	return fmt.Sprintf("job-%04d", s.jobs)
+}
+
+// handle runs one job, sending status replies back as they are generated
+func (s *server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	printer := emulator.NewThrottled(emulator.New(), s.throttle)
+	var raw []byte
+
+	// Replies are sent from their own goroutine since a throttled printer
+	// only answers once its buffer has been printed.  Reads block until a
+	// reply is ready or the deadline passes, which is moved up to stop them
+	// once the job is over.
+	var mu sync.Mutex
+	done := false
+	replies := make(chan struct{})
+	go func() {
+		defer close(replies)
+
+		reply := make([]byte, 64)
+		for {
+			mu.Lock()
+			if done {
+				mu.Unlock()
+				return
+			}
+			printer.SetReadDeadline(time.Now().Add(time.Second))
+			mu.Unlock()
+
+			n, _ := printer.Read(reply)
+			if n > 0 {
+				if _, err := conn.Write(reply[:n]); err != nil {
+					log.Printf("%s: could not send status reply: %s", conn.RemoteAddr(), err)
+				}
+			}
+		}
+	}()
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			raw = append(raw, buf[:n]...)
+			printer.Write(buf[:n])
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			log.Printf("%s: %s", conn.RemoteAddr(), err)
+			break
+		}
+	}
+
+	mu.Lock()
+	done = true
+	printer.SetReadDeadline(time.Now())
+	mu.Unlock()
+	<-replies
+	printer.Drain()
+
+	if len(raw) == 0 {
+		return
+	}
+
+	stats := printer.Stats()
+	if stats.Dropped > 0 {
+		log.Printf("%s: dropped %d of %d bytes, at most %d were buffered", conn.RemoteAddr(), stats.Dropped, stats.Received, stats.MaxBuffered)
+	}
+
+	name := s.nextJob()
+	err := s.save(name, raw, printer.Printer().Pages())
+	if err != nil {
+		log.Printf("%s: could not save %s: %s", conn.RemoteAddr(), name, err)
+		return
+	}
+	log.Printf("%s: saved %s (%d bytes)", conn.RemoteAddr(), name, len(raw))
+}
+
+func (s *server) save(name string, raw []byte, pages []*image.Gray) error {
+	err := os.WriteFile(filepath.Join(s.out, name+".bin"), raw, 0644)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(filepath.Join(s.out, name+".png"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = png.Encode(file, stitch(pages))
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// stitch draws every page one after another with a gray gap between them
+func stitch(pages []*image.Gray) image.Image {
+	height := 0
+	for i, page := range pages {
+		if i > 0 {
+			height += pageGap
+		}
+		height += page.Bounds().Dy()
+	}
+	if height == 0 {
+		// Nothing was printed but PNGs need at least one row
+		height = 1
+	}
+
+	img := image.NewGray(image.Rect(0, 0, emulator.PaperWidth, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Gray{Y: 0x80}), image.Point{}, draw.Src)
+
+	y := 0
+	for _, page := range pages {
+		r := page.Bounds().Add(image.Pt(0, y))
+		draw.Draw(img, r, page, image.Point{}, draw.Src)
+		y += page.Bounds().Dy() + pageGap
+	}
+
+	return img
+}
+
+var galleryTemplate = template.Must(template.New("gallery").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>hoin-emulator</title>
+<style>
+body { font-family: sans-serif; background: #ddd; }
+.job { display: inline-block; vertical-align: top; margin: 1em; }
+.job img { display: block; background: white; box-shadow: 0 0 4px #888; }
+</style>
+</head>
+<body>
+<h1>Received jobs</h1>
+{{range .}}<div class="job">
+<p>{{.}} &middot; <a href="jobs/{{.}}.bin">raw</a></p>
+<a href="jobs/{{.}}.png"><img src="jobs/{{.}}.png" width="288"></a>
+</div>
+{{else}}<p>No jobs yet</p>
+{{end}}</body>
+</html>
+`))
+
+// gallery serves an index of every job, newest first, and the job files
+func (s *server) gallery() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/jobs/", http.StripPrefix("/jobs/", http.FileServer(http.Dir(s.out))))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		matches, err := filepath.Glob(filepath.Join(s.out, "job-*.png"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var jobs []string
+		for _, match := range matches {
+			jobs = append(jobs, strings.TrimSuffix(filepath.Base(match), ".png"))
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(jobs)))
+
+		err = galleryTemplate.Execute(w, jobs)
+		if err != nil {
+			log.Printf("could not render gallery: %s", err)
+		}
+	})
+	return mux
+}
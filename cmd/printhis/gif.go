@@ -0,0 +1,48 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"image/gif"
+)
+
+// gifFrames composites each frame of an animated GIF onto the frames before
+// it, following each frame's disposal method, and returns the full images
+//
+// The background is white to match the paper.
+func gifFrames(g *gif.GIF) []image.Image {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	canvas := image.NewRGBA(bounds)
+	draw.Draw(canvas, bounds, image.White, image.Point{}, draw.Src)
+
+	clone := func(img *image.RGBA) *image.RGBA {
+		c := image.NewRGBA(img.Bounds())
+		copy(c.Pix, img.Pix)
+		return c
+	}
+
+	var frames []image.Image
+	for i, frame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = clone(canvas)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		frames = append(frames, clone(canvas))
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.White, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return frames
+}
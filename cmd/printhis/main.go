@@ -1,24 +1,35 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"net"
 	"io"
+	"net/url"
+	"path/filepath"
 	"strings"
 	"strconv"
-	"path/filepath"
+	"bytes"
 	"image"
 	"image/png"
-	"image/jpeg"
+	_ "image/jpeg"
+	"image/gif"
+	"time"
 
 	"github.com/alexflint/go-arg"
 	"github.com/joeyak/hoin-printer"
+	"github.com/joeyak/hoin-printer/discover"
+	_ "github.com/joeyak/hoin-printer/emulator"
+	"github.com/joeyak/hoin-printer/session"
 )
 
 type CmdText struct {
-	Input    string `arg:"positional" help:"Print text from a file.  STDIN is used if no filename is given or the filename is a single dash."`
-	TabWidth int    `arg:"-t,--tab-width" default:"4" help:"Width of the tabstop in spaces."`
+	Input    string  `arg:"positional" help:"Print text from a file.  STDIN is used if no filename is given or the filename is a single dash."`
+	TabWidth int     `arg:"-t,--tab-width" default:"4" help:"Width of the tabstop in spaces."`
+	TTF      string  `arg:"--ttf" help:"Render the text with a TrueType or OpenType font file instead of the printer fonts."`
+	Size     float64 `arg:"-s,--size" default:"32" help:"Font size in dots when using --ttf."`
+	Align    string  `arg:"--align" default:"left" help:"Alignment when using --ttf.  One of left, center or right."`
 }
 
 type CmdTabs struct {
@@ -26,7 +37,68 @@ type CmdTabs struct {
 }
 
 type CmdImage struct {
-	Input string `arg:"positional,required" help:"Image file to print.  Currently supports PNG and JPEG image formats."`
+	Input      string  `arg:"positional,required" help:"Image file to print.  Currently supports PNG, JPEG and GIF image formats."`
+	Frames     int     `arg:"--frames" help:"Print every Nth frame of an animated GIF with its frame number."`
+	Preview    string  `arg:"--preview" help:"Write the bitmap that would be sent to the printer to this PNG file instead of printing."`
+	Brightness float64 `arg:"--brightness" help:"Shift brightness from -1 (black) to 1 (white)."`
+	Contrast   float64 `arg:"--contrast" default:"1" help:"Contrast factor.  1 leaves the image unchanged."`
+	Gamma      float64 `arg:"--gamma" default:"1" help:"Gamma correction.  Values above 1 lighten mid-tones."`
+	Sharpen    float64 `arg:"--sharpen" help:"Unsharp mask amount."`
+	Radius     int     `arg:"--sharpen-radius" default:"2" help:"Unsharp mask radius in dots."`
+	AutoLevels bool    `arg:"--auto-levels" help:"Stretch the image to use the full range from black to white."`
+	Invert     bool    `arg:"--invert" help:"Swap black and white."`
+	DotGain    float64 `arg:"--dot-gain" default:"0" help:"Dot gain compensation at 50% coverage.  Thermal heads are typically around 0.25."`
+}
+
+func (c *CmdImage) Pipeline() hoin.Pipeline {
+	var pipeline hoin.Pipeline
+	if c.AutoLevels {
+		pipeline = append(pipeline, hoin.AutoLevels())
+	}
+	if c.Brightness != 0 {
+		pipeline = append(pipeline, hoin.Brightness(c.Brightness))
+	}
+	if c.Contrast != 1 {
+		pipeline = append(pipeline, hoin.Contrast(c.Contrast))
+	}
+	if c.Gamma != 1 {
+		pipeline = append(pipeline, hoin.Gamma(c.Gamma))
+	}
+	if c.Sharpen != 0 {
+		pipeline = append(pipeline, hoin.UnsharpMask(c.Radius, c.Sharpen))
+	}
+	if c.DotGain != 0 {
+		pipeline = append(pipeline, hoin.DotGain(c.DotGain))
+	}
+	if c.Invert {
+		pipeline = append(pipeline, hoin.Invert())
+	}
+	return pipeline
+}
+
+type CmdBanner struct {
+	Text      string  `arg:"positional,required" help:"Text of the banner."`
+	TTF       string  `arg:"--ttf" help:"TrueType or OpenType font file.  Go Bold is used if no font is given."`
+	Height    float64 `arg:"--height" default:"60" help:"Height of the letters across the paper in millimeters."`
+	Repeat    int     `arg:"-r,--repeat" default:"1" help:"Number of times to print the text."`
+	Separator string  `arg:"--separator" default:" * " help:"Separator printed between each repeat."`
+}
+
+type CmdRecord struct {
+	Output string `arg:"positional,required" help:"Capture file to write."`
+	Listen string `arg:"-l,--listen" default:":9100" help:"Address to accept the application's connection on."`
+}
+
+type CmdReplay struct {
+	Input    string        `arg:"positional,required" help:"Capture file to replay."`
+	Speed    float64       `arg:"--speed" default:"1" help:"Replay speed.  1 keeps the original timing and 0 sends everything as fast as possible."`
+	MaxDelay time.Duration `arg:"--max-delay" help:"Longest pause between two events, such as 500ms."`
+}
+
+type CmdDiscover struct {
+	Networks     []string      `arg:"positional" help:"CIDR ranges to scan, such as 192.168.1.0/24.  The networks of the local interfaces are used if none are given."`
+	Port         int           `arg:"--port" default:"9100" help:"Port the printers listen on."`
+	ProbeTimeout time.Duration `arg:"--probe-timeout" default:"500ms" help:"How long each address gets to answer."`
 }
 
 type CmdCut struct { }
@@ -37,14 +109,35 @@ type CmdFeed struct {
 }
 
 type Arguments struct {
-	Text  *CmdText  `arg:"subcommand:text"  help:"Print text"`
-	Tabs  *CmdTabs  `arg:"subcommand:tabs"  help:"Print the tabstop locations"`
-	Image *CmdImage `arg:"subcommand:image" help:"Print an image"`
-	Cut   *CmdCut   `arg:"subcommand:cut"   help:"Cut the paper"`
-	Feed  *CmdFeed  `arg:"subcommand:feed"  help:"Feed the paper"`
+	Text     *CmdText     `arg:"subcommand:text"     help:"Print text"`
+	Tabs     *CmdTabs     `arg:"subcommand:tabs"     help:"Print the tabstop locations"`
+	Image    *CmdImage    `arg:"subcommand:image"    help:"Print an image"`
+	Banner   *CmdBanner   `arg:"subcommand:banner"   help:"Print a banner along the length of the paper"`
+	Record   *CmdRecord   `arg:"subcommand:record"   help:"Forward one connection to the printer and record it"`
+	Replay   *CmdReplay   `arg:"subcommand:replay"   help:"Replay a recorded capture to the printer"`
+	Discover *CmdDiscover `arg:"subcommand:discover" help:"Scan the network for printers"`
+	Cut      *CmdCut      `arg:"subcommand:cut"      help:"Cut the paper"`
+	Feed     *CmdFeed     `arg:"subcommand:feed"     help:"Feed the paper"`
+
+	Printer string `arg:"-p,--printer" help:"Connection string of the printer, such as tcp://192.168.1.23:9100, usb:///dev/usb/lp0, serial:///dev/ttyS0?baud=9600, file:///tmp/out.bin or emulator:///tmp/jobs.  Defaults to $HOIN_PRINTER, then 192.168.1.23:9100."`
 
 	Address string `arg:"-a,--addr" help:"IP address and port of printer"`
-	Device string `arg:"-d,--dev" help:"USB device of printer"`
+	Device string `arg:"-d,--dev" help:"USB device of printer, or auto to find the first ESC/POS printer"`
+
+	Serial    string `arg:"--serial" help:"Serial port of printer, such as /dev/ttyUSB0"`
+	Baud      int    `arg:"--baud" default:"19200" help:"Baud rate of the serial port."`
+	DataBits  int    `arg:"--data-bits" default:"8" help:"Data bits of the serial port."`
+	Parity    string `arg:"--parity" default:"none" help:"Parity of the serial port.  One of none, odd or even."`
+	StopBits  int    `arg:"--stop-bits" default:"1" help:"Stop bits of the serial port."`
+	Handshake string `arg:"--handshake" default:"none" help:"Handshake of the serial port.  One of none, rtscts or xonxoff."`
+
+	Flow       string        `arg:"--flow" help:"How images and long text are paced.  One of status, ready, delay, rate, xonxoff or none.  ready stops when the paper runs out or the cover is opened.  Defaults to status, or delay for USB devices that can't be read from."`
+	FlowDelay  time.Duration `arg:"--flow-delay" default:"35ms" help:"Pause after each image band with --flow delay."`
+	FlowRate   int           `arg:"--flow-rate" default:"20000" help:"Bytes per second with --flow rate."`
+	XonTimeout time.Duration `arg:"--xon-timeout" default:"10s" help:"How long to wait for XON with --flow xonxoff."`
+	Timeout    time.Duration `arg:"--timeout" help:"Give up if the printer hasn't finished after this long, such as 30s.  0 waits forever."`
+	Buffer     int           `arg:"--buffer" help:"Send commands in one write once this many bytes are waiting instead of a write per command.  0 is unbuffered."`
+	Reconnect  int           `arg:"--reconnect" help:"Redial a network printer this many times if the connection drops.  0 doesn't redial."`
 }
 
 func (a *Arguments) Description() string {
@@ -57,8 +150,22 @@ func main() {
 	args := &Arguments{}
 	arg.MustParse(args)
 
-	if args.Address == "" && args.Device == "" {
-		args.Address = "192.168.1.23:9100"
+	if args.Image != nil && args.Image.Preview != "" {
+		err := previewImage(args.Image)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args.Discover != nil {
+		err := discoverPrinters(args)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
 
 	printer, closer, err := connect(args)
@@ -68,36 +175,106 @@ func main() {
 	}
 	defer closer.Close()
 
+	if args.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), args.Timeout)
+		defer cancel()
+		*printer = printer.WithContext(ctx)
+	}
+
 	err = run(args, printer)
+	if err == nil {
+		err = printer.Flush()
+	}
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
+func flowControl(args *Arguments) (hoin.FlowControl, error) {
+	switch args.Flow {
+	case "":
+		return nil, nil
+	case "status":
+		return hoin.StatusPolling(), nil
+	case "ready":
+		return hoin.ReadyPolling(), nil
+	case "delay":
+		return hoin.FixedDelay(args.FlowDelay), nil
+	case "rate":
+		return hoin.ByteRate(args.FlowRate), nil
+	case "xonxoff":
+		return hoin.XonXoff(args.XonTimeout), nil
+	case "none":
+		return hoin.NoFlowControl(), nil
+	}
+	return nil, fmt.Errorf("unknown flow control %q", args.Flow)
+}
+
+// pathDSN returns a connection string for the file at path, which may be
+// relative
+func pathDSN(scheme, path string, query url.Values) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("could not find %s: %w", path, err)
+	}
+
+	u := url.URL{Scheme: scheme, Path: path, RawQuery: query.Encode()}
+	return u.String(), nil
+}
+
+// dsn returns the connection string for the printer flags
+func dsn(args *Arguments) (string, error) {
+	switch {
+	case args.Printer != "":
+		return args.Printer, nil
+	case args.Address != "":
+		return "tcp://" + args.Address, nil
+	case args.Device == "auto":
+		return "usb://", nil
+	case args.Device != "":
+		// Any device or file that can be written to works, not only USB
+		// printers
+		return pathDSN("usb", args.Device, nil)
+	case args.Serial != "":
+		query := url.Values{}
+		query.Set("baud", strconv.Itoa(args.Baud))
+		query.Set("data_bits", strconv.Itoa(args.DataBits))
+		query.Set("parity", args.Parity)
+		query.Set("stop_bits", strconv.Itoa(args.StopBits))
+		query.Set("handshake", args.Handshake)
+		return pathDSN("serial", args.Serial, query)
+	}
+	return "", nil
+}
+
 func connect(args *Arguments) (*hoin.Printer, io.Closer, error) {
-	var printer hoin.Printer
-	var cl io.Closer
-	if args.Address != "" {
-		conn, err := net.Dial("tcp", args.Address)
-		if err != nil {
-			return nil, nil, fmt.Errorf("unable to dial: %w", err)
-		}
-		cl = conn
-		printer = hoin.NewPrinter(conn)
+	flow, err := flowControl(args)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	} else if args.Device != "" {
-		file, err := os.OpenFile(args.Device, os.O_RDWR, 0660)
-		if err != nil {
-			return nil, nil, fmt.Errorf("unable to open device: %w", err)
-		}
-		cl = file
-		printer = hoin.NewPrinter(file)
-	} else {
-		return nil, nil, fmt.Errorf("Unable to determine printer address")
+	var opts []hoin.Option
+	if flow != nil {
+		opts = append(opts, hoin.WithFlowControl(flow))
+	}
+	if args.Buffer > 0 {
+		opts = append(opts, hoin.WithBuffering(args.Buffer))
+	}
+	if args.Reconnect > 0 {
+		opts = append(opts, hoin.WithReconnect(hoin.ReconnectOptions{Attempts: args.Reconnect}))
+	}
+
+	address, err := dsn(args)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return &printer, cl, nil
+	printer, err := hoin.Open(address, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &printer, printer, nil
 }
 
 func run(args *Arguments, printer *hoin.Printer) error {
@@ -129,6 +306,10 @@ func run(args *Arguments, printer *hoin.Printer) error {
 			return err
 		}
 
+		if args.Text.TTF != "" {
+			return printTTF(printer, string(raw), args.Text)
+		}
+
 		words := strings.Split(string(raw), " ")
 
 		for _, word := range words {
@@ -176,29 +357,53 @@ func run(args *Arguments, printer *hoin.Printer) error {
 		}
 
 	case args.Image != nil:
-		file, err := os.Open(args.Image.Input)
+		err := printImage(printer, args.Image)
 		if err != nil {
 			return err
 		}
-		defer file.Close()
-		var img image.Image
 
-		switch filepath.Ext(args.Image.Input) {
-		case ".png":
-			img, err = png.Decode(file)
+	case args.Banner != nil:
+		opts := hoin.BannerOptions{
+			HeightMM:  args.Banner.Height,
+			Repeat:    args.Banner.Repeat,
+			Separator: args.Banner.Separator,
+		}
+
+		if args.Banner.TTF != "" {
+			font, err := hoin.LoadFont(args.Banner.TTF)
+			if err != nil {
+				return err
+			}
+			opts.Font = font
+		}
+
+		err := printer.PrintBanner(args.Banner.Text, opts)
+		if err != nil {
+			return err
+		}
 
-		case ".jpg", ".jpeg":
-			img, err = jpeg.Decode(file)
+	case args.Record != nil:
+		err := record(printer, args.Record)
+		if err != nil {
+			return err
+		}
 
-		default:
-			return fmt.Errorf("unsupported image format: %s", filepath.Ext(args.Image.Input))
+	case args.Replay != nil:
+		file, err := os.Open(args.Replay.Input)
+		if err != nil {
+			return err
 		}
+		defer file.Close()
 
+		capture, err := session.ReadCapture(file)
 		if err != nil {
 			return err
 		}
 
-		err = printer.PrintImage24(img, hoin.DoubleDensity)
+		err = session.Replay(printer, capture, session.ReplayOptions{
+			Speed:    args.Replay.Speed,
+			MaxDelay: args.Replay.MaxDelay,
+		})
 		if err != nil {
 			return err
 		}
@@ -209,3 +414,180 @@ func run(args *Arguments, printer *hoin.Printer) error {
 
 	return nil
 }
+
+func printTTF(printer *hoin.Printer, text string, args *CmdText) error {
+	font, err := hoin.LoadFont(args.TTF)
+	if err != nil {
+		return err
+	}
+
+	var justify hoin.Justification
+	switch args.Align {
+	case "left":
+		justify = hoin.LeftJustify
+	case "center":
+		justify = hoin.CenterJustify
+	case "right":
+		justify = hoin.RightJustify
+	default:
+		return fmt.Errorf("unknown alignment: %s", args.Align)
+	}
+
+	return printer.PrintText(strings.TrimRight(text, "\n"), font, hoin.TextOptions{
+		Size:    args.Size,
+		Justify: justify,
+	})
+}
+
+// discoverPrinters prints every printer found on the networks
+func discoverPrinters(args *Arguments) error {
+	ctx := context.Background()
+	if args.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, args.Timeout)
+		defer cancel()
+	}
+
+	networks := args.Discover.Networks
+	if len(networks) == 0 {
+		var err error
+		networks, err = discover.LocalNetworks()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, network := range networks {
+		fmt.Fprintf(os.Stderr, "scanning %s\n", network)
+		results, err := discover.Scan(ctx, network, discover.Options{
+			Port:    args.Discover.Port,
+			Timeout: args.Discover.ProbeTimeout,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, result := range results {
+			fmt.Println(result)
+		}
+	}
+
+	return nil
+}
+
+func previewImage(args *CmdImage) error {
+	if args.Frames != 0 {
+		return fmt.Errorf("--preview cannot be used with --frames")
+	}
+
+	raw, err := os.ReadFile(args.Input)
+	if err != nil {
+		return err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(args.Preview)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = png.Encode(file, hoin.PreviewImage24(args.Pipeline().Apply(img)))
+	if err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+func printImage(printer *hoin.Printer, args *CmdImage) error {
+	raw, err := os.ReadFile(args.Input)
+	if err != nil {
+		return err
+	}
+
+	if args.Frames == 0 {
+		img, _, err := image.Decode(bytes.NewReader(raw))
+		if err != nil {
+			return err
+		}
+		return printer.PrintImage24(args.Pipeline().Apply(img), hoin.DoubleDensity)
+	}
+
+	if args.Frames < 0 {
+		return fmt.Errorf("--frames must be a positive number")
+	}
+
+	_, format, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	if format != "gif" {
+		return fmt.Errorf("--frames requires a GIF but %s is a %s", args.Input, format)
+	}
+
+	g, err := gif.DecodeAll(bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+
+	frames := gifFrames(g)
+	for i := 0; i < len(frames); i += args.Frames {
+		err = printer.Printf("Frame %d/%d\n", i+1, len(frames))
+		if err != nil {
+			return err
+		}
+
+		err = printer.PrintImage24(args.Pipeline().Apply(frames[i]), hoin.DoubleDensity)
+		if err != nil {
+			return fmt.Errorf("could not print frame %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// record accepts a single connection from an application, forwards it to the
+// printer and records everything sent in either direction
+func record(printer *hoin.Printer, args *CmdRecord) error {
+	listener, err := net.Listen("tcp", args.Listen)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	fmt.Fprintf(os.Stderr, "waiting for a connection on %s\n", listener.Addr())
+	conn, err := listener.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	file, err := os.Create(args.Output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	recorder, err := session.NewRecorder(printer, file)
+	if err != nil {
+		return err
+	}
+
+	// Status replies go back to the application until the printer is closed
+	go io.Copy(conn, recorder)
+
+	_, err = io.Copy(recorder, conn)
+	if err != nil {
+		return err
+	}
+
+	if err := recorder.Err(); err != nil {
+		return err
+	}
+	return file.Close()
+}
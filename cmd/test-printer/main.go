@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"reflect"
@@ -9,10 +10,13 @@ import (
 	"time"
 
 	"github.com/joeyak/hoin-printer"
+	_ "github.com/joeyak/hoin-printer/emulator"
 )
 
+var dsn = flag.String("printer", "", "Connection string of the printer, such as tcp://192.168.1.23:9100.  Defaults to $HOIN_PRINTER, then "+hoin.DefaultPrinterIP+".")
+
 func runTest(testName string, testFunc func(hoin.Printer) error) error {
-	printer, err := hoin.NewIpPrinter(hoin.DefaultPrinterIP)
+	printer, err := hoin.Open(*dsn)
 	if err != nil {
 		return fmt.Errorf("failed test %s: %w", testName, err)
 	}
@@ -31,7 +35,7 @@ func runTest(testName string, testFunc func(hoin.Printer) error) error {
 }
 
 func cleanup() {
-	printer, err := hoin.NewIpPrinter(hoin.DefaultPrinterIP)
+	printer, err := hoin.Open(*dsn)
 	if err != nil {
 		fmt.Printf("could not create new printer to feed lines: %s\n", err)
 		os.Exit(1)
@@ -43,6 +47,8 @@ func cleanup() {
 }
 
 func main() {
+	flag.Parse()
+
 	tests := []func(hoin.Printer) error{
 		testBeep,
 		testHT,
@@ -0,0 +1,163 @@
+package hoin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// DefaultDialTimeout is how long NewIpPrinter waits for the printer to
+// accept the connection
+const DefaultDialTimeout = 10 * time.Second
+
+// ErrTimeout is returned when the printer doesn't respond before a deadline,
+// like a status request that is never answered.  Use errors.Is to check for
+// it.
+var ErrTimeout = errors.New("printer timed out")
+
+type timeoutError struct {
+	err error
+}
+
+func (e timeoutError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrTimeout, e.err)
+}
+
+func (e timeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+func (e timeoutError) Unwrap() error {
+	return e.err
+}
+
+// checkTimeout turns deadline errors from the transport or context into an
+// error matching ErrTimeout
+func checkTimeout(err error) error {
+	var netErr net.Error
+	if errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) {
+		return timeoutError{err}
+	}
+	return err
+}
+
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// WithContext returns a copy of the printer that stops when ctx is done
+//
+// Writes and status reads use the deadline of ctx and are interrupted when
+// it is cancelled if the transport has SetWriteDeadline and SetReadDeadline,
+// like net.Conn and *os.File.  Other transports only check ctx before each
+// write or read.  Pauses from the flow control end early too.
+//
+// This is used instead of a ...Context variant of every Printer method, so
+// the whole API gains a context without doubling in size.  SharedPrinter has
+// Context variants since its calls also wait for the printer.
+func (p Printer) WithContext(ctx context.Context) Printer {
+	p.ctx = ctx
+	return p
+}
+
+// NewIpPrinterContext connects to the printer at addr, giving up when ctx is
+// done.  The context only applies to dialling, use WithContext for the
+// commands sent after, which redialling with WithReconnect also stops for.
+func NewIpPrinterContext(ctx context.Context, addr string, opts ...Option) (Printer, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return Printer{}, fmt.Errorf("unable to dial: %w", checkTimeout(err))
+	}
+
+	p := NewPrinter(conn, opts...)
+	if p.reconnect != nil {
+		p.dst = newReconnector(conn, addr, *p.reconnect)
+	}
+	return p, nil
+}
+
+// contextErr returns why the context of the printer is done, if it is
+func (p Printer) contextErr() error {
+	if p.ctx == nil {
+		return nil
+	}
+	return checkTimeout(p.ctx.Err())
+}
+
+// withDeadline runs op with the deadline of the context set by setDeadline,
+// which may be nil if the transport doesn't support deadlines.  Cancelling
+// the context moves the deadline to now so op returns straight away.
+func (p Printer) withDeadline(setDeadline func(time.Time) error, op func() (int, error)) (int, error) {
+	if err := p.contextErr(); err != nil {
+		return 0, err
+	}
+
+	if p.ctx == nil || setDeadline == nil {
+		n, err := op()
+		return n, checkTimeout(err)
+	}
+
+	deadline, hasDeadline := p.ctx.Deadline()
+	err := setDeadline(deadline)
+	if errors.Is(err, os.ErrNoDeadline) {
+		// Wrappers like session.Recorder only have deadlines when what they
+		// wrap does
+		n, err := op()
+		return n, checkTimeout(err)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("could not set deadline: %w", err)
+	}
+	defer setDeadline(time.Time{})
+
+	if p.ctx.Done() != nil {
+		stop := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			select {
+			case <-p.ctx.Done():
+				setDeadline(time.Now())
+			case <-stop:
+			}
+		}()
+		defer func() {
+			close(stop)
+			<-stopped
+		}()
+	}
+
+	n, err := op()
+	if err != nil && p.ctx.Err() != nil {
+		// Report the cancellation instead of the deadline it caused
+		return n, checkTimeout(p.ctx.Err())
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) && hasDeadline && !time.Now().Before(deadline) {
+		// The transport can time out just before the context notices
+		return n, checkTimeout(context.DeadlineExceeded)
+	}
+	return n, checkTimeout(err)
+}
+
+// sleep pauses for d or until the context is done
+func (p Printer) sleep(d time.Duration) error {
+	if p.ctx == nil {
+		time.Sleep(d)
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-p.ctx.Done():
+		return checkTimeout(p.ctx.Err())
+	}
+}
@@ -0,0 +1,103 @@
+package hoin_test
+
+import (
+	"context"
+	"errors"
+	"image"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/joeyak/hoin-printer"
+)
+
+// silentPrinter is the other end of a connection to a printer that reads
+// everything but never answers a status request
+func silentPrinter(t *testing.T) net.Conn {
+	client, server := net.Pipe()
+	go io.Copy(io.Discard, server)
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client
+}
+
+func TestContextTimeout(t *testing.T) {
+	printer := hoin.NewPrinter(silentPrinter(t))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := printer.WithContext(ctx).TransmitErrorStatus()
+	if !errors.Is(err, hoin.ErrTimeout) {
+		t.Fatalf("expected %q to be a timeout", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %q to wrap the context error", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("status took %s to time out", elapsed)
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	printer := hoin.NewPrinter(silentPrinter(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	err := printer.WithContext(ctx).PrintImage24(image.NewGray(image.Rect(0, 0, 8, 48)), hoin.DoubleDensity)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %q to be a cancellation", err)
+	}
+	if errors.Is(err, hoin.ErrTimeout) {
+		t.Errorf("cancellation %q was reported as a timeout", err)
+	}
+
+	// The printer still works without the context
+	err = printer.Initialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContextWriteTimeout(t *testing.T) {
+	// Nothing reads from the other end of the pipe so writes block
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := hoin.NewPrinter(client).WithContext(ctx).Println("Hello")
+	if !errors.Is(err, hoin.ErrTimeout) {
+		t.Fatalf("expected %q to be a timeout", err)
+	}
+}
+
+func TestContextFlowControl(t *testing.T) {
+	device := silentPrinter(t)
+	printer := hoin.NewPrinter(device, hoin.WithFlowControl(hoin.FixedDelay(time.Hour)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := printer.WithContext(ctx).PrintImage8(image.NewGray(image.Rect(0, 0, 8, 8)), hoin.SingleDensity)
+	if !errors.Is(err, hoin.ErrTimeout) {
+		t.Fatalf("expected %q to be a timeout", err)
+	}
+}
+
+func TestNewIpPrinterContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := hoin.NewIpPrinterContext(ctx, "127.0.0.1:9")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %q to be a cancellation", err)
+	}
+}
@@ -0,0 +1,357 @@
+// Package decoder turns a stream of ESC/POS bytes back into the commands
+// sent by the hoin package.
+package decoder
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/joeyak/hoin-printer/escpos"
+)
+
+const (
+	HT  = escpos.HT
+	LF  = escpos.LF
+	CR  = escpos.CR
+	GS  = escpos.GS
+	ESC = escpos.ESC
+	DLE = escpos.DLE
+	EOT = escpos.EOT
+	NUL = escpos.NUL
+)
+
+// Bytes is raw data that is written as hex in JSON
+type Bytes []byte
+
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(b))
+}
+
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+
+	*b, err = hex.DecodeString(s)
+	return err
+}
+
+// Param is a decoded parameter of a command
+type Param struct {
+	Name    string `json:"name"`
+	Value   int    `json:"value"`
+	Meaning string `json:"meaning,omitempty"`
+}
+
+// Command is a single command or run of text found in the stream
+type Command struct {
+	Offset      int     `json:"offset"`
+	Raw         Bytes   `json:"raw"`
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Params      []Param `json:"params,omitempty"`
+	// Text is set for runs of printable text and bar code data
+	Text string `json:"text,omitempty"`
+	// Data is the image or tab data following the parameters
+	Data Bytes `json:"data,omitempty"`
+	// Error is set for unknown or truncated commands
+	Error string `json:"error,omitempty"`
+}
+
+const (
+	errUnknown   = "unknown command"
+	errTruncated = "truncated command"
+)
+
+// String formats the command as a line of a listing
+func (c Command) String() string {
+	raw := strings.ToUpper(hex.EncodeToString(c.Raw))
+	var spaced []string
+	for i := 0; i < len(raw) && i < 16; i += 2 {
+		spaced = append(spaced, raw[i:i+2])
+	}
+	rawText := strings.Join(spaced, " ")
+	if len(c.Raw) > 8 {
+		rawText += " ..."
+	}
+
+	mnemonic := c.Name
+	if c.Name == "TEXT" {
+		mnemonic = strconv.Quote(c.Text)
+	}
+	for _, p := range c.Params {
+		mnemonic += " " + strconv.Itoa(p.Value)
+	}
+
+	var details []string
+	if c.Description != "" {
+		details = append(details, c.Description)
+	}
+	for _, p := range c.Params {
+		if p.Meaning != "" {
+			details = append(details, fmt.Sprintf("%s=%s", p.Name, p.Meaning))
+		}
+	}
+	if c.Name != "TEXT" && c.Text != "" {
+		details = append(details, fmt.Sprintf("data=%q", c.Text))
+	}
+	if len(c.Data) > 0 {
+		details = append(details, fmt.Sprintf("[%d bytes of data]", len(c.Data)))
+	}
+	if c.Error != "" {
+		details = append(details, "!! "+c.Error)
+	}
+
+	return fmt.Sprintf("%08X  %-27s  %-20s  %s", c.Offset, rawText, mnemonic, strings.Join(details, "; "))
+}
+
+// Decode splits b into commands
+//
+// Unknown commands and commands cut off by the end of b are returned with
+// Error set.
+func Decode(b []byte) []Command {
+	var commands []Command
+	for offset := 0; offset < len(b); {
+		c := decode(b[offset:])
+		c.Offset = offset
+		c.Raw = b[offset : offset+len(c.Raw)]
+		commands = append(commands, c)
+		offset += len(c.Raw)
+	}
+	return commands
+}
+
+func isText(c byte) bool {
+	return c >= 0x20 && c != 0x7F
+}
+
+// decode decodes the command at the start of b.  Raw is set to the bytes
+// used by the command.
+func decode(b []byte) Command {
+	switch b[0] {
+	case HT:
+		return Command{Raw: b[:1], Name: "HT", Description: "Horizontal tab"}
+	case LF:
+		return Command{Raw: b[:1], Name: "LF", Description: "Print and line feed"}
+	case CR:
+		return Command{Raw: b[:1], Name: "CR", Description: "Print and carriage return"}
+	case ESC:
+		return decodeESC(b)
+	case GS:
+		return decodeGS(b)
+	case DLE:
+		return decodeDLE(b)
+	}
+
+	if !isText(b[0]) {
+		return Command{Raw: b[:1], Name: fmt.Sprintf("0x%02X", b[0]), Error: "unknown control character"}
+	}
+
+	n := 1
+	for n < len(b) && isText(b[n]) {
+		n++
+	}
+	return Command{Raw: b[:n], Name: "TEXT", Text: string(b[:n])}
+}
+
+func truncated(b []byte, name string) Command {
+	return Command{Raw: b, Name: name, Error: errTruncated}
+}
+
+// fixed decodes a command with one byte per parameter
+func fixed(b []byte, name, description string, params ...string) Command {
+	size := 2 + len(params)
+	if len(b) < size {
+		return truncated(b, name)
+	}
+
+	c := Command{Raw: b[:size], Name: name, Description: description}
+	for i, p := range params {
+		c.Params = append(c.Params, Param{Name: p, Value: int(b[2+i])})
+	}
+	return c
+}
+
+// mean sets the meaning of parameter i from a list of choices
+func mean(c Command, i int, choices map[int]string) Command {
+	if c.Error != "" || i >= len(c.Params) {
+		return c
+	}
+
+	meaning, ok := choices[c.Params[i].Value]
+	if !ok {
+		meaning = "invalid"
+	}
+	c.Params[i].Meaning = meaning
+	return c
+}
+
+var onOff = map[int]string{0: "off", 1: "on"}
+
+func name(prefix string, c byte) string {
+	if isText(c) {
+		return prefix + " " + string(c)
+	}
+	return fmt.Sprintf("%s 0x%02X", prefix, c)
+}
+
+func decodeESC(b []byte) Command {
+	if len(b) < 2 {
+		return truncated(b, "ESC")
+	}
+
+	n := name("ESC", b[1])
+	switch b[1] {
+	case '@':
+		return fixed(b, n, "Initialize printer")
+	case '2':
+		return fixed(b, n, "Select default line spacing")
+	case '3':
+		return fixed(b, n, "Set line spacing", "n")
+	case 'B':
+		return fixed(b, n, "Beep", "n", "t")
+	case 'J':
+		return fixed(b, n, "Print and feed paper", "n")
+	case 'd':
+		return fixed(b, n, "Print and feed lines", "n")
+	case 't':
+		return fixed(b, n, "Select character code table", "n")
+	case 'E':
+		return mean(fixed(b, n, "Turn emphasized mode on/off", "n"), 0, onOff)
+	case 'G':
+		return mean(fixed(b, n, "Turn double-strike mode on/off", "n"), 0, onOff)
+	case 'V':
+		return mean(fixed(b, n, "Turn 90 degree rotation on/off", "n"), 0, onOff)
+	case 'M':
+		return mean(fixed(b, n, "Select character font", "n"), 0, map[int]string{0: "font A", 1: "font B"})
+	case 'a':
+		return mean(fixed(b, n, "Select justification", "n"), 0, map[int]string{0: "left", 1: "center", 2: "right"})
+	case '\\':
+		return fixed(b, n, "Set relative print position", "nL", "nH")
+	case 'D':
+		for i := 2; i < len(b); i++ {
+			if b[i] == NUL {
+				return Command{Raw: b[:i+1], Name: n, Description: "Set horizontal tab positions", Data: b[2:i]}
+			}
+		}
+		return truncated(b, n)
+	case '*':
+		c := mean(fixed(b, n, "Select bit-image mode", "m", "nL", "nH"), 0, map[int]string{
+			0:  "8-dot single density",
+			1:  "8-dot double density",
+			32: "24-dot single density",
+			33: "24-dot double density",
+		})
+		if c.Error != "" {
+			return c
+		}
+
+		bytesPerCol := 1
+		if c.Params[0].Value >= 32 {
+			bytesPerCol = 3
+		}
+
+		size := 5 + (c.Params[1].Value|c.Params[2].Value<<8)*bytesPerCol
+		if len(b) < size {
+			return truncated(b, n)
+		}
+		c.Raw, c.Data = b[:size], b[5:size]
+		return c
+	}
+
+	return Command{Raw: b[:2], Name: n, Error: errUnknown}
+}
+
+// barCodes are named after the escpos Bc constants
+var barCodes = map[int]string{
+	int(escpos.BcUPCA):    "UPC-A",
+	int(escpos.BcUPCE):    "UPC-E",
+	int(escpos.BcJAN13):   "JAN13",
+	int(escpos.BcJAN8):    "JAN8",
+	int(escpos.BcCODE39):  "CODE39",
+	int(escpos.BcITF):     "ITF",
+	int(escpos.BcCODABAR): "CODABAR",
+	int(escpos.BcCODE93):  "CODE93",
+	int(escpos.BcCODE128): "CODE128",
+}
+
+func decodeGS(b []byte) Command {
+	if len(b) < 2 {
+		return truncated(b, "GS")
+	}
+
+	n := name("GS", b[1])
+	switch b[1] {
+	case 'B':
+		return mean(fixed(b, n, "Turn white/black reverse printing on/off", "n"), 0, onOff)
+	case 'H':
+		return mean(fixed(b, n, "Select HRI position", "n"), 0, map[int]string{0: "none", 1: "above", 2: "below", 3: "both"})
+	case 'h':
+		return fixed(b, n, "Select bar code height", "n")
+	case 'I':
+		return mean(fixed(b, n, "Transmit printer ID", "n"), 0, map[int]string{
+			1: "model", 2: "type", 3: "ROM version",
+			65: "firmware", 66: "manufacturer", 67: "model name", 68: "serial number",
+		})
+	case 'V':
+		if len(b) < 3 {
+			return truncated(b, n)
+		}
+		if b[2] == 65 || b[2] == 66 {
+			return fixed(b, n, "Feed and cut paper", "m", "n")
+		}
+		return fixed(b, n, "Cut paper", "m")
+	case 'k':
+		c := mean(fixed(b, n, "Print bar code", "m"), 0, barCodes)
+		if c.Error != "" {
+			return c
+		}
+
+		if c.Params[0].Value <= 6 {
+			for i := 3; i < len(b); i++ {
+				if b[i] == NUL {
+					c.Raw, c.Text = b[:i+1], string(b[3:i])
+					return c
+				}
+			}
+			return truncated(b, n)
+		}
+
+		c = fixed(b, n, c.Description, "m", "n")
+		c = mean(c, 0, barCodes)
+		if c.Error != "" {
+			return c
+		}
+
+		size := 4 + c.Params[1].Value
+		if len(b) < size {
+			return truncated(b, n)
+		}
+		c.Raw, c.Text = b[:size], string(b[4:size])
+		return c
+	}
+
+	return Command{Raw: b[:2], Name: n, Error: errUnknown}
+}
+
+func decodeDLE(b []byte) Command {
+	if len(b) < 2 {
+		return truncated(b, "DLE")
+	}
+
+	if b[1] != EOT {
+		return Command{Raw: b[:2], Name: name("DLE", b[1]), Error: errUnknown}
+	}
+
+	return mean(fixed(b, "DLE EOT", "Real-time status transmission", "n"), 0, map[int]string{
+		1: "printer status",
+		2: "offline status",
+		3: "error status",
+		4: "paper sensor status",
+	})
+}
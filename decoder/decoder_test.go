@@ -0,0 +1,191 @@
+package decoder_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joeyak/hoin-printer/decoder"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  decoder.Command
+	}{
+		{"Text", "Hello", decoder.Command{Raw: []byte("Hello"), Name: "TEXT", Text: "Hello"}},
+		{"LF", "\n", decoder.Command{Raw: []byte("\n"), Name: "LF", Description: "Print and line feed"}},
+		{"HT", "\t", decoder.Command{Raw: []byte("\t"), Name: "HT", Description: "Horizontal tab"}},
+		{"ESC @", "\x1b@", decoder.Command{Raw: []byte("\x1b@"), Name: "ESC @", Description: "Initialize printer"}},
+		{"ESC J", "\x1bJ\x50", decoder.Command{
+			Raw: []byte("\x1bJ\x50"), Name: "ESC J", Description: "Print and feed paper",
+			Params: []decoder.Param{{Name: "n", Value: 80}},
+		}},
+		{"ESC a", "\x1ba\x01", decoder.Command{
+			Raw: []byte("\x1ba\x01"), Name: "ESC a", Description: "Select justification",
+			Params: []decoder.Param{{Name: "n", Value: 1, Meaning: "center"}},
+		}},
+		{"ESC E invalid", "\x1bE\x05", decoder.Command{
+			Raw: []byte("\x1bE\x05"), Name: "ESC E", Description: "Turn emphasized mode on/off",
+			Params: []decoder.Param{{Name: "n", Value: 5, Meaning: "invalid"}},
+		}},
+		{"ESC D", "\x1bD\x08\x10\x00", decoder.Command{
+			Raw: []byte("\x1bD\x08\x10\x00"), Name: "ESC D", Description: "Set horizontal tab positions",
+			Data: []byte{8, 16},
+		}},
+		{"ESC *", "\x1b*\x21\x01\x00\xff\x00\xff", decoder.Command{
+			Raw: []byte("\x1b*\x21\x01\x00\xff\x00\xff"), Name: "ESC *", Description: "Select bit-image mode",
+			Params: []decoder.Param{
+				{Name: "m", Value: 33, Meaning: "24-dot double density"},
+				{Name: "nL", Value: 1},
+				{Name: "nH", Value: 0},
+			},
+			Data: []byte{0xff, 0x00, 0xff},
+		}},
+		{"GS V", "\x1dV\x00", decoder.Command{
+			Raw: []byte("\x1dV\x00"), Name: "GS V", Description: "Cut paper",
+			Params: []decoder.Param{{Name: "m", Value: 0}},
+		}},
+		{"GS V feed", "\x1dV\x41\x28", decoder.Command{
+			Raw: []byte("\x1dV\x41\x28"), Name: "GS V", Description: "Feed and cut paper",
+			Params: []decoder.Param{{Name: "m", Value: 65}, {Name: "n", Value: 40}},
+		}},
+		{"GS k NUL", "\x1dk\x02490123456789\x00", decoder.Command{
+			Raw: []byte("\x1dk\x02490123456789\x00"), Name: "GS k", Description: "Print bar code",
+			Params: []decoder.Param{{Name: "m", Value: 2, Meaning: "JAN13"}},
+			Text:   "490123456789",
+		}},
+		{"GS k length", "\x1dk\x49\x03ABC", decoder.Command{
+			Raw: []byte("\x1dk\x49\x03ABC"), Name: "GS k", Description: "Print bar code",
+			Params: []decoder.Param{{Name: "m", Value: 73, Meaning: "CODE128"}, {Name: "n", Value: 3}},
+			Text:   "ABC",
+		}},
+		{"DLE EOT", "\x10\x04\x02", decoder.Command{
+			Raw: []byte("\x10\x04\x02"), Name: "DLE EOT", Description: "Real-time status transmission",
+			Params: []decoder.Param{{Name: "n", Value: 2, Meaning: "offline status"}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands := decoder.Decode([]byte(tt.input))
+			if len(commands) != 1 {
+				t.Fatalf("expected one command but got %v", commands)
+			}
+			if !reflect.DeepEqual(commands[0], tt.want) {
+				t.Errorf("got %#v want %#v", commands[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeStream(t *testing.T) {
+	commands := decoder.Decode([]byte("\x1b@Total\n\x1dV\x00"))
+
+	want := []struct {
+		offset int
+		name   string
+	}{
+		{0, "ESC @"},
+		{2, "TEXT"},
+		{7, "LF"},
+		{8, "GS V"},
+	}
+	if len(commands) != len(want) {
+		t.Fatalf("expected %d commands but got %v", len(want), commands)
+	}
+	for i, w := range want {
+		if commands[i].Offset != w.offset || commands[i].Name != w.name {
+			t.Errorf("expected %s at %d but got %s at %d", w.name, w.offset, commands[i].Name, commands[i].Offset)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		raw   int
+	}{
+		{"ESC", "\x1b", "truncated command", 1},
+		{"GS", "\x1d", "truncated command", 1},
+		{"DLE", "\x10", "truncated command", 1},
+		{"ESC J", "\x1bJ", "truncated command", 2},
+		{"ESC D", "\x1bD\x08\x10", "truncated command", 4},
+		{"ESC *", "\x1b*\x00\x04\x00\xff", "truncated command", 6},
+		{"GS V", "\x1dV", "truncated command", 2},
+		{"GS k NUL", "\x1dk\x04ABC", "truncated command", 6},
+		{"GS k length", "\x1dk\x49\x05AB", "truncated command", 6},
+		{"Unknown ESC", "\x1by", "unknown command", 2},
+		{"Unknown GS", "\x1dy", "unknown command", 2},
+		{"Unknown DLE", "\x10\x14", "unknown command", 2},
+		{"Control character", "\x07", "unknown control character", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands := decoder.Decode([]byte(tt.input))
+			if len(commands) != 1 {
+				t.Fatalf("expected one command but got %v", commands)
+			}
+			if commands[0].Error != tt.want {
+				t.Errorf("expected error %q but got %q", tt.want, commands[0].Error)
+			}
+			if len(commands[0].Raw) != tt.raw {
+				t.Errorf("expected %d bytes to be used but got %d", tt.raw, len(commands[0].Raw))
+			}
+		})
+	}
+}
+
+func TestCommandString(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		contains []string
+	}{
+		{"Text", "Hi", []string{"00000000", "48 69", `"Hi"`}},
+		{"Params", "\x1ba\x02", []string{"1B 61 02", "ESC a 2", "Select justification", "n=right"}},
+		{"Data", "\x1b*\x00\x10\x00" + strings.Repeat("\xff", 16), []string{"1B 2A 00 10 00 FF FF FF ...", "[16 bytes of data]"}},
+		{"Bar code", "\x1dk\x04AB\x00", []string{`data="AB"`}},
+		{"Error", "\x1by", []string{"ESC y", "!! unknown command"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := decoder.Decode([]byte(tt.input))[0].String()
+			for _, s := range tt.contains {
+				if !strings.Contains(line, s) {
+					t.Errorf("expected %q in %q", s, line)
+				}
+			}
+		})
+	}
+}
+
+func TestCommandJSON(t *testing.T) {
+	commands := decoder.Decode([]byte("\x1bD\x08\x00\x1by"))
+
+	b, err := json.Marshal(commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `[{"offset":0,"raw":"1b440800","name":"ESC D","description":"Set horizontal tab positions","data":"08"},` +
+		`{"offset":4,"raw":"1b79","name":"ESC y","error":"unknown command"}]`
+	if string(b) != want {
+		t.Errorf("got %s want %s", b, want)
+	}
+
+	var decoded []decoder.Command
+	err = json.Unmarshal(b, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, commands) {
+		t.Errorf("expected JSON to round trip but got %#v", decoded)
+	}
+}
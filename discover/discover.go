@@ -0,0 +1,255 @@
+// Package discover finds network printers by scanning a range of addresses
+// for the raw printing port.
+//
+// Anything accepting connections is sent DLE EOT to check it answers like an
+// ESC/POS printer, then GS I for its manufacturer, model and firmware.
+package discover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/joeyak/hoin-printer"
+)
+
+// DefaultPort is the raw printing port used by network printers
+const DefaultPort = 9100
+
+// maxHosts stops a typo like /8 from scanning for hours
+const maxHosts = 1 << 16
+
+// Options controls a scan
+type Options struct {
+	// Port to connect to.  DefaultPort is used when Port is 0.
+	Port int
+	// Timeout is how long each address gets to accept the connection and
+	// answer.  500ms is used when Timeout is 0.
+	Timeout time.Duration
+	// Concurrency is how many addresses are probed at once.  64 is used when
+	// Concurrency is 0.
+	Concurrency int
+}
+
+func (o Options) withDefaults() Options {
+	if o.Port == 0 {
+		o.Port = DefaultPort
+	}
+	if o.Timeout == 0 {
+		o.Timeout = 500 * time.Millisecond
+	}
+	if o.Concurrency == 0 {
+		o.Concurrency = 64
+	}
+	return o
+}
+
+// Result is an address that accepted a connection
+type Result struct {
+	Addr string
+	// ESCPOS is true when a status request was answered.  Otherwise something
+	// accepted the connection but didn't answer like an ESC/POS printer.
+	ESCPOS bool
+
+	// IDs reported by the printer, empty if it doesn't support GS I
+	Manufacturer string
+	Model        string
+	Firmware     string
+	SerialNumber string
+}
+
+func (r Result) String() string {
+	if !r.ESCPOS {
+		return fmt.Sprintf("%s (not ESC/POS)", r.Addr)
+	}
+	if r.Manufacturer == "" && r.Model == "" {
+		return r.Addr
+	}
+	return fmt.Sprintf("%s %s %s (firmware %s, serial %s)", r.Addr, r.Manufacturer, r.Model, r.Firmware, r.SerialNumber)
+}
+
+// Probe connects to addr and fingerprints what answers
+//
+// An error is only returned when the connection is refused or times out.
+func Probe(ctx context.Context, addr string, timeout time.Duration) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return Result{}, fmt.Errorf("could not connect to %s: %w", addr, err)
+	}
+	defer conn.Close()
+
+	result := Result{Addr: addr}
+	printer := hoin.NewPrinter(conn, hoin.WithFlowControl(hoin.NoFlowControl())).WithContext(ctx)
+
+	// Replies without the fixed bits of a status are ignored, so anything
+	// else times out
+	_, err = printer.TransmitPrinterStatus()
+	if err != nil {
+		return result, nil
+	}
+	result.ESCPOS = true
+
+	ids := []struct {
+		n  hoin.PrinterIDType
+		id *string
+	}{
+		{hoin.IDManufacturer, &result.Manufacturer},
+		{hoin.IDModelName, &result.Model},
+		{hoin.IDFirmware, &result.Firmware},
+		{hoin.IDSerialNumber, &result.SerialNumber},
+	}
+	for _, id := range ids {
+		b, err := printer.TransmitPrinterID(id.n)
+		if err != nil {
+			// Later replies can't be trusted once one is missing
+			break
+		}
+		*id.id = string(b)
+	}
+
+	return result, nil
+}
+
+// Scan probes every address in network, a CIDR range like 192.168.1.0/24,
+// and returns what answered in address order
+func Scan(ctx context.Context, network string, opts Options) ([]Result, error) {
+	opts = opts.withDefaults()
+
+	ips, err := hosts(network)
+	if err != nil {
+		return nil, fmt.Errorf("could not scan %s: %w", network, err)
+	}
+
+	addrs := make(chan net.IP)
+	var mu sync.Mutex
+	var results []Result
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range addrs {
+				addr := net.JoinHostPort(ip.String(), strconv.Itoa(opts.Port))
+				result, err := Probe(ctx, addr, opts.Timeout)
+				if err != nil {
+					continue
+				}
+
+				mu.Lock()
+				results = append(results, result)
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, ip := range ips {
+		select {
+		case addrs <- ip:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(addrs)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("could not scan %s: %w", network, ctx.Err())
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, _, _ := net.SplitHostPort(results[i].Addr)
+		b, _, _ := net.SplitHostPort(results[j].Addr)
+		return bytes.Compare(net.ParseIP(a).To16(), net.ParseIP(b).To16()) < 0
+	})
+	return results, nil
+}
+
+// hosts lists the addresses in network, leaving out the network and
+// broadcast addresses of IPv4 ranges
+func hosts(network string) ([]net.IP, error) {
+	ip, ipNet, err := net.ParseCIDR(network)
+	if err != nil {
+		return nil, err
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	ones, bits := ipNet.Mask.Size()
+	if bits-ones > 16 {
+		return nil, fmt.Errorf("more than %d addresses", maxHosts)
+	}
+
+	var ips []net.IP
+	for ip := ip.Mask(ipNet.Mask); ip != nil && ipNet.Contains(ip); ip = next(ip) {
+		ips = append(ips, ip)
+	}
+
+	if bits == 32 && len(ips) > 2 {
+		ips = ips[1 : len(ips)-1]
+	}
+	return ips, nil
+}
+
+// next returns the address after ip, or nil if ip is the last address
+func next(ip net.IP) net.IP {
+	n := append(net.IP{}, ip...)
+	for i := len(n) - 1; i >= 0; i-- {
+		n[i]++
+		if n[i] != 0 {
+			return n
+		}
+	}
+	return nil
+}
+
+// LocalNetworks lists the IPv4 networks of the interfaces that are up,
+// narrowed to /24 so large office networks scan quickly
+func LocalNetworks() ([]string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("could not list interfaces: %w", err)
+	}
+
+	var networks []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.To4() == nil {
+				continue
+			}
+
+			mask := ipNet.Mask
+			if ones, _ := mask.Size(); ones < 24 {
+				mask = net.CIDRMask(24, 32)
+			}
+			network := &net.IPNet{IP: ipNet.IP.To4().Mask(mask), Mask: mask}
+			networks = append(networks, network.String())
+		}
+	}
+
+	if len(networks) == 0 {
+		return nil, errors.New("no IPv4 networks found")
+	}
+	return networks, nil
+}
@@ -0,0 +1,143 @@
+package discover_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/joeyak/hoin-printer/discover"
+	"github.com/joeyak/hoin-printer/emulator"
+)
+
+// serve answers every connection to listener with its own emulated printer
+func serve(listener net.Listener, id emulator.ID) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer conn.Close()
+
+			printer := emulator.New()
+			printer.SetID(id)
+
+			b := make([]byte, 256)
+			for {
+				n, err := conn.Read(b)
+				if err != nil {
+					return
+				}
+				printer.Write(b[:n])
+
+				for {
+					n, err := printer.Read(b)
+					if err != nil {
+						break
+					}
+					conn.Write(b[:n])
+				}
+			}
+		}()
+	}
+}
+
+// silent accepts connections to listener and never answers
+func silent(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			b := make([]byte, 256)
+			for {
+				if _, err := conn.Read(b); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// listen listens on the same port at each loopback address
+func listen(t *testing.T, hosts ...string) ([]net.Listener, int) {
+	var listeners []net.Listener
+	port := "0"
+	for _, host := range hosts {
+		listener, err := net.Listen("tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			t.Skipf("could not listen on %s: %s", host, err)
+		}
+		t.Cleanup(func() { listener.Close() })
+
+		_, port, _ = net.SplitHostPort(listener.Addr().String())
+		listeners = append(listeners, listener)
+	}
+
+	n, _ := strconv.Atoi(port)
+	return listeners, n
+}
+
+func TestScan(t *testing.T) {
+	listeners, port := listen(t, "127.0.0.2", "127.0.0.5", "127.0.0.6")
+
+	id := emulator.DefaultID
+	go serve(listeners[0], id)
+	id.ModelName = "HOP-E801"
+	id.SerialNumber = "SECOND"
+	go serve(listeners[1], id)
+	go silent(listeners[2])
+
+	results, err := discover.Scan(context.Background(), "127.0.0.0/29", discover.Options{
+		Port:    port,
+		Timeout: 200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []discover.Result{
+		{
+			Addr: net.JoinHostPort("127.0.0.2", strconv.Itoa(port)), ESCPOS: true,
+			Manufacturer: "HOIN", Model: "HOP-E802", Firmware: "1.00", SerialNumber: "EMULATOR",
+		},
+		{
+			Addr: net.JoinHostPort("127.0.0.5", strconv.Itoa(port)), ESCPOS: true,
+			Manufacturer: "HOIN", Model: "HOP-E801", Firmware: "1.00", SerialNumber: "SECOND",
+		},
+		{Addr: net.JoinHostPort("127.0.0.6", strconv.Itoa(port))},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results but got %v", len(want), results)
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("expected %+v but got %+v", want[i], results[i])
+		}
+	}
+}
+
+func TestScanCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := discover.Scan(ctx, "127.0.0.0/24", discover.Options{Port: 1})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %q to be a cancellation", err)
+	}
+}
+
+func TestScanInvalid(t *testing.T) {
+	for _, network := range []string{"127.0.0.1", "10.0.0.0/8"} {
+		_, err := discover.Scan(context.Background(), network, discover.Options{})
+		if err == nil {
+			t.Errorf("expected %s to fail", network)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package hoin
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PrinterEnv is the environment variable Open uses when dsn is empty
+const PrinterEnv = "HOIN_PRINTER"
+
+var parities = map[string]Parity{
+	"none": ParityNone,
+	"odd":  ParityOdd,
+	"even": ParityEven,
+}
+
+var handshakes = map[string]Handshake{
+	"none":    HandshakeNone,
+	"rtscts":  HandshakeRTSCTS,
+	"xonxoff": HandshakeXonXoff,
+}
+
+// Opener opens the printer described by a parsed connection string
+type Opener func(u *url.URL, opts []Option) (Printer, error)
+
+var (
+	schemesMu sync.RWMutex
+	schemes   = map[string]Opener{
+		"tcp":    openTCP,
+		"usb":    openUSB,
+		"serial": openSerialDSN,
+		"file":   openFile,
+	}
+)
+
+// RegisterScheme makes Open use open for connection strings starting with
+// scheme://, replacing any opener already registered for it.  The emulator
+// package registers emulator:// this way when it is imported.
+func RegisterScheme(scheme string, open Opener) {
+	schemesMu.Lock()
+	defer schemesMu.Unlock()
+	schemes[scheme] = open
+}
+
+// Open opens the printer described by a connection string
+//
+//	tcp://192.168.1.23:9100                  network printer, port 9100 if left out
+//	tcp://192.168.1.23?reconnect=5           redial up to 5 times when the connection drops
+//	usb:///dev/usb/lp0                       USB printer, usb:// finds the first one
+//	serial:///dev/ttyS0?baud=9600            serial printer, also taking data_bits,
+//	                                         parity, stop_bits and handshake
+//	file:///tmp/out.bin                      write the commands to a file
+//	emulator://                              emulated printer that throws the paper away,
+//	                                         when the emulator package is imported
+//	emulator:///tmp/jobs                     emulated printer writing its pages as PNGs
+//	                                         to the directory on Close
+//
+// An address without a scheme is a network printer.  When dsn is empty the
+// PrinterEnv environment variable is used, then DefaultPrinterIP.
+func Open(dsn string, opts ...Option) (Printer, error) {
+	if dsn == "" {
+		dsn = os.Getenv(PrinterEnv)
+	}
+	if dsn == "" {
+		dsn = DefaultPrinterIP
+	}
+	if !strings.Contains(dsn, "://") {
+		dsn = "tcp://" + dsn
+	}
+
+	errMsg := fmt.Sprintf("could not open printer %s: %%w", dsn)
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return Printer{}, fmt.Errorf(errMsg, err)
+	}
+
+	schemesMu.RLock()
+	open, ok := schemes[u.Scheme]
+	schemesMu.RUnlock()
+	if !ok {
+		return Printer{}, fmt.Errorf(errMsg, fmt.Errorf("unsupported scheme %q", u.Scheme))
+	}
+
+	printer, err := open(u, opts)
+	if err != nil {
+		return Printer{}, fmt.Errorf(errMsg, err)
+	}
+	return printer, nil
+}
+
+// query returns the parameters of u, failing on any not in allowed
+func query(u *url.URL, allowed ...string) (url.Values, error) {
+	values := u.Query()
+	for key := range values {
+		if !inSlice(key, allowed...) {
+			return nil, fmt.Errorf("unknown parameter %q", key)
+		}
+	}
+	return values, nil
+}
+
+// queryInt parses parameter key of values, returning 0 when it isn't set
+func queryInt(values url.Values, key string) (int, error) {
+	if !values.Has(key) {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(values.Get(key))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
+
+func openTCP(u *url.URL, opts []Option) (Printer, error) {
+	values, err := query(u, "reconnect")
+	if err != nil {
+		return Printer{}, err
+	}
+
+	attempts, err := queryInt(values, "reconnect")
+	if err != nil {
+		return Printer{}, err
+	}
+	if attempts > 0 {
+		opts = append(opts, WithReconnect(ReconnectOptions{Attempts: attempts}))
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "9100")
+	}
+	return NewIpPrinter(addr, opts...)
+}
+
+func openUSB(u *url.URL, opts []Option) (Printer, error) {
+	if u.Host != "" && u.Host != "auto" {
+		return Printer{}, errors.New("usb paths start with a slash, like usb:///dev/usb/lp0")
+	}
+	return NewDevicePrinter(u.Path, opts...)
+}
+
+func openSerialDSN(u *url.URL, opts []Option) (Printer, error) {
+	values, err := query(u, "baud", "data_bits", "parity", "stop_bits", "handshake")
+	if err != nil {
+		return Printer{}, err
+	}
+
+	var serial SerialOptions
+	ints := []struct {
+		key string
+		n   *int
+	}{
+		{"baud", &serial.Baud},
+		{"data_bits", &serial.DataBits},
+		{"stop_bits", &serial.StopBits},
+	}
+	for _, i := range ints {
+		*i.n, err = queryInt(values, i.key)
+		if err != nil {
+			return Printer{}, err
+		}
+	}
+
+	if values.Has("parity") {
+		var ok bool
+		serial.Parity, ok = parities[values.Get("parity")]
+		if !ok {
+			return Printer{}, fmt.Errorf("unknown parity %q", values.Get("parity"))
+		}
+	}
+	if values.Has("handshake") {
+		var ok bool
+		serial.Handshake, ok = handshakes[values.Get("handshake")]
+		if !ok {
+			return Printer{}, fmt.Errorf("unknown handshake %q", values.Get("handshake"))
+		}
+	}
+
+	return NewSerialPrinter(u.Path, serial, opts...)
+}
+
+// openFile writes to a file, which can't answer status requests and never
+// needs pacing
+func openFile(u *url.URL, opts []Option) (Printer, error) {
+	if u.Host != "" {
+		return Printer{}, errors.New("file paths start with a slash, like file:///tmp/out.bin")
+	}
+
+	file, err := os.Create(u.Path)
+	if err != nil {
+		return Printer{}, err
+	}
+
+	opts = append([]Option{WithFlowControl(NoFlowControl())}, opts...)
+	return NewPrinter(writeOnly{file}, opts...), nil
+}
@@ -0,0 +1,104 @@
+package hoin_test
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joeyak/hoin-printer"
+)
+
+func TestOpenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.bin")
+
+	printer, err := hoin.Open("file://" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = printer.Println("Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = printer.TransmitErrorStatus()
+	if !errors.Is(err, hoin.ErrWriteOnly) {
+		t.Errorf("expected %q to be %q", err, hoin.ErrWriteOnly)
+	}
+
+	err = printer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "Hello\n" {
+		t.Errorf("expected the file to have %q but got %q", "Hello\n", got)
+	}
+}
+
+func TestOpenTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	received := make(chan string, 3)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			b, _ := io.ReadAll(conn)
+			received <- string(b)
+			conn.Close()
+		}
+	}()
+
+	t.Setenv(hoin.PrinterEnv, listener.Addr().String())
+	dsns := []string{"tcp://" + listener.Addr().String(), listener.Addr().String(), ""}
+
+	for _, dsn := range dsns {
+		printer, err := hoin.Open(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		printer.Print(dsn)
+		printer.Close()
+
+		select {
+		case got := <-received:
+			if got != dsn {
+				t.Errorf("expected the printer to get %q but got %q", dsn, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%q never connected", dsn)
+		}
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	dsns := []string{
+		"lpt://1",
+		"tcp://127.0.0.1:9100?speed=1",
+		"serial:///dev/ttyS0?baud=fast",
+		"serial:///dev/ttyS0?parity=mark",
+		"file://out.bin",
+	}
+
+	for _, dsn := range dsns {
+		printer, err := hoin.Open(dsn)
+		if err == nil {
+			printer.Close()
+			t.Errorf("expected %s to fail", dsn)
+		}
+	}
+}
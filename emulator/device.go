@@ -0,0 +1,165 @@
+package emulator
+
+import (
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/joeyak/hoin-printer/escpos"
+)
+
+type trigger struct {
+	offset int
+	change func(*Status)
+	err    error
+}
+
+// Device is a scriptable fake printer for testing
+//
+// It records every byte written to it, answers DLE EOT status requests
+// from its Status and GS I requests from its ID.  Nothing is rendered, use
+// Printer for that.
+//
+// Like real hardware, DLE EOT is answered wherever it appears in the byte
+// stream, including inside image or bar code data.
+type Device struct {
+	mu sync.Mutex
+
+	status    Status
+	id        ID
+	written   []byte
+	responses []byte
+	triggers  []trigger
+}
+
+// NewDevice creates a device reporting no errors and DefaultID
+func NewDevice() *Device {
+	return &Device{id: DefaultID}
+}
+
+// SetStatus replaces the status reported by the device
+func (d *Device) SetStatus(s Status) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status = s
+}
+
+// Status returns the status reported by the device
+func (d *Device) Status() Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status
+}
+
+// SetID replaces the ID reported by the device
+func (d *Device) SetID(id ID) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.id = id
+}
+
+func (d *Device) addTrigger(t trigger) {
+	d.triggers = append(d.triggers, t)
+	sort.SliceStable(d.triggers, func(i, j int) bool {
+		return d.triggers[i].offset < d.triggers[j].offset
+	})
+}
+
+// At calls change once offset bytes have been written to the device
+//
+// An offset of 0 or one that has already passed changes the status
+// immediately.
+func (d *Device) At(offset int, change func(*Status)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if offset <= len(d.written) {
+		change(&d.status)
+		return
+	}
+	d.addTrigger(trigger{offset: offset, change: change})
+}
+
+// FailAt makes writes fail with err once offset bytes have been written
+//
+// The write that reaches offset reports the bytes written before the
+// failure, and every write after that fails without writing anything.
+func (d *Device) FailAt(offset int, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.addTrigger(trigger{offset: offset, err: err})
+}
+
+// Bytes returns a copy of everything written to the device
+func (d *Device) Bytes() []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]byte{}, d.written...)
+}
+
+// Reset forgets everything written to the device and any unread replies.
+// The status and pending triggers are kept.
+func (d *Device) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.written = nil
+	d.responses = nil
+}
+
+// Write records b and replies to any status requests in it
+func (d *Device) Write(b []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i, c := range b {
+		if err := d.fire(); err != nil {
+			return i, err
+		}
+
+		d.written = append(d.written, c)
+
+		n := len(d.written)
+		if n >= 3 && d.written[n-3] == escpos.DLE && d.written[n-2] == escpos.EOT {
+			d.responses = append(d.responses, d.status.Reply(c))
+		}
+		if n >= 3 && d.written[n-3] == escpos.GS && d.written[n-2] == 'I' {
+			d.responses = append(d.responses, d.id.Reply(c)...)
+		}
+	}
+
+	// Failures at the end of b are left for the next write
+	_ = d.fire()
+
+	return len(b), nil
+}
+
+// fire runs every trigger that has been reached and returns the error of
+// any failure trigger
+func (d *Device) fire() error {
+	for len(d.triggers) > 0 && d.triggers[0].offset <= len(d.written) {
+		t := d.triggers[0]
+		if t.err != nil {
+			return t.err
+		}
+
+		t.change(&d.status)
+		d.triggers = d.triggers[1:]
+	}
+	return nil
+}
+
+// Read returns replies to status requests
+//
+// io.EOF is returned when there is nothing to read.
+func (d *Device) Read(b []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.responses) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(b, d.responses)
+	d.responses = d.responses[n:]
+	return n, nil
+}
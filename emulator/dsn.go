@@ -0,0 +1,59 @@
+package emulator
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/joeyak/hoin-printer"
+)
+
+func init() {
+	hoin.RegisterScheme("emulator", open)
+}
+
+// saved is an emulated printer that saves its pages when closed
+type saved struct {
+	*Printer
+	dir string
+}
+
+func (s saved) Close() error {
+	if s.dir == "" {
+		return nil
+	}
+
+	err := os.MkdirAll(s.dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	for i := range s.Pages() {
+		file, err := os.Create(filepath.Join(s.dir, fmt.Sprintf("page-%03d.png", i+1)))
+		if err != nil {
+			return err
+		}
+
+		err = s.EncodePNG(file, i)
+		closeErr := file.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// open opens emulator:// connection strings for hoin.Open
+func open(u *url.URL, opts []hoin.Option) (hoin.Printer, error) {
+	if u.Host != "" {
+		return hoin.Printer{}, errors.New("emulator paths start with a slash, like emulator:///tmp/jobs")
+	}
+
+	// Replies come straight back so status polling works
+	return hoin.NewPrinter(saved{New(), u.Path}, opts...), nil
+}
@@ -0,0 +1,49 @@
+package emulator_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joeyak/hoin-printer"
+)
+
+func TestOpenEmulator(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "jobs")
+
+	printer, err := hoin.Open("emulator://" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = printer.Println("Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = printer.TransmitErrorStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = printer.Cut()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = printer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "page-001.png"))
+	if err != nil {
+		t.Errorf("expected the page to be saved: %s", err)
+	}
+}
+
+func TestOpenEmulatorInvalid(t *testing.T) {
+	printer, err := hoin.Open("emulator://jobs")
+	if err == nil {
+		printer.Close()
+		t.Error("expected a relative path to fail")
+	}
+}
@@ -0,0 +1,59 @@
+package emulator
+
+import (
+	"github.com/joeyak/hoin-printer/escpos"
+)
+
+// ID is what the printer reports to GS I requests
+type ID struct {
+	// Single byte IDs (GS I 1 to 3)
+	Model      byte
+	Type       byte
+	ROMVersion byte
+
+	// Text IDs (GS I 65 to 68)
+	Firmware     string
+	Manufacturer string
+	ModelName    string
+	SerialNumber string
+}
+
+// DefaultID is the ID of a new printer.  The type has an auto cutter.
+var DefaultID = ID{
+	Model:        0x20,
+	Type:         0x02,
+	ROMVersion:   0x01,
+	Firmware:     "1.00",
+	Manufacturer: "HOIN",
+	ModelName:    "HOP-E802",
+	SerialNumber: "EMULATOR",
+}
+
+// Reply returns the bytes sent in reply to GS I n
+//
+// Text IDs start with 0x5F and end with NUL.  Unknown values of n aren't
+// answered.
+func (id ID) Reply(n byte) []byte {
+	var text string
+	switch n {
+	case 1, 49:
+		return []byte{id.Model}
+	case 2, 50:
+		return []byte{id.Type}
+	case 3, 51:
+		return []byte{id.ROMVersion}
+	case 65:
+		text = id.Firmware
+	case 66:
+		text = id.Manufacturer
+	case 67:
+		text = id.ModelName
+	case 68:
+		text = id.SerialNumber
+	default:
+		return nil
+	}
+
+	reply := append([]byte{0x5F}, text...)
+	return append(reply, escpos.NUL)
+}
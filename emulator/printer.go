@@ -0,0 +1,691 @@
+// Package emulator implements a virtual HOIN printer that interprets the
+// ESC/POS commands sent by the hoin package and renders them to images.
+//
+// Bar codes are drawn as a stripe pattern derived from their data rather
+// than encoded in their symbology, so they take up the right space on the
+// page but can't be scanned.
+package emulator
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"sync"
+
+	"github.com/joeyak/hoin-printer/escpos"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	// Printable width in dots of an 80mm paper roll
+	PaperWidth = escpos.PaperWidth
+
+	// Width in dots of the narrowest bar code bar
+	barCodeModule = 2
+	// Quiet zone in dots on each side of a bar code
+	barCodeQuiet = 10
+)
+
+// item is something waiting in the line buffer to be printed
+type item struct {
+	x   int
+	img *image.Gray
+}
+
+// Printer is a virtual printer that renders commands onto paper-width pages
+//
+// It implements io.ReadWriter so it can be passed to hoin.NewPrinter.  Text
+// is drawn with a fixed bitmap font scaled to the Font A and Font B cell
+// sizes.  Bar codes are drawn as a stripe pattern derived from their data
+// and are not scannable.  Each cut finishes the current page and starts a
+// new one, unless nothing was printed since the last cut.
+type Printer struct {
+	mu sync.Mutex
+
+	status    Status
+	id        ID
+	pending   []byte
+	responses []byte
+
+	pages []*image.Gray
+	page  *image.Gray
+	y     int
+
+	// layout has the position on the line, the paper fed and the settings
+	// that change them, shared with hoin's DryRun
+	layout *escpos.Layout
+	line   []item
+
+	bold    bool
+	reverse bool
+	justify int
+
+	beeps int
+}
+
+// New creates a blank virtual printer
+func New() *Printer {
+	p := &Printer{id: DefaultID, layout: escpos.NewLayout()}
+	p.reset()
+	return p
+}
+
+// reset restores the settings changed by commands to their defaults
+func (p *Printer) reset() {
+	p.layout.Reset()
+	p.bold = false
+	p.reverse = false
+	p.justify = 0
+}
+
+// Write interprets b as printer commands
+//
+// Incomplete commands at the end of b are kept until the rest of the command
+// is written.
+func (p *Printer) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pending = append(p.pending, b...)
+	for len(p.pending) > 0 {
+		n := p.execute(p.pending)
+		if n == 0 {
+			break
+		}
+		p.pending = p.pending[n:]
+	}
+
+	return len(b), nil
+}
+
+// Read returns replies to status requests
+//
+// io.EOF is returned when there is nothing to read.
+func (p *Printer) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.responses) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(b, p.responses)
+	p.responses = p.responses[n:]
+	return n, nil
+}
+
+// SetStatus replaces the status reported by the printer
+func (p *Printer) SetStatus(s Status) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status = s
+}
+
+// Status returns the status reported by the printer
+func (p *Printer) Status() Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+// SetID replaces the ID reported by the printer
+func (p *Printer) SetID(id ID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.id = id
+}
+
+// Beeps returns the number of beeps requested so far
+func (p *Printer) Beeps() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.beeps
+}
+
+// Pages returns every cut page followed by the current page if anything has
+// been printed on it
+//
+// Text still in the line buffer without a line feed is not included.
+func (p *Printer) Pages() []*image.Gray {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pages := append([]*image.Gray{}, p.pages...)
+	if p.y > 0 {
+		pages = append(pages, crop(p.page, p.y))
+	}
+	return pages
+}
+
+// Fed returns the total paper fed in dots across every page
+func (p *Printer) Fed() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.layout.Fed
+}
+
+// EncodePNG writes page i as a PNG
+func (p *Printer) EncodePNG(w io.Writer, i int) error {
+	return png.Encode(w, p.Pages()[i])
+}
+
+// execute runs the command at the start of b and returns the number of
+// bytes used, or 0 when b does not hold the whole command yet
+func (p *Printer) execute(b []byte) int {
+	switch b[0] {
+	case escpos.LF:
+		p.flushLine(p.layout.LineSpacing)
+		return 1
+	case escpos.CR:
+		// Auto line feed is off, so CR does nothing
+		return 1
+	case escpos.HT:
+		p.layout.Tab()
+		return 1
+	case escpos.ESC:
+		return p.executeESC(b)
+	case escpos.GS:
+		return p.executeGS(b)
+	case escpos.DLE:
+		return p.executeDLE(b)
+	}
+
+	if b[0] < 0x20 {
+		// Unsupported control character
+		return 1
+	}
+
+	p.character(b[0])
+	return 1
+}
+
+// need returns true if b is at least n bytes long
+func need(b []byte, n int) bool {
+	return len(b) >= n
+}
+
+func (p *Printer) executeESC(b []byte) int {
+	if !need(b, 2) {
+		return 0
+	}
+
+	switch b[1] {
+	case '@':
+		p.reset()
+		return 2
+	case '2':
+		p.layout.LineSpacing = escpos.DefaultLineSpacing
+		return 2
+	case 'B':
+		if !need(b, 4) {
+			return 0
+		}
+		p.beeps += int(b[2])
+		return 4
+	case '3', 'J', 'd', 'E', 'G', 'V', 'M', 'a':
+		if !need(b, 3) {
+			return 0
+		}
+		n := int(b[2])
+		switch b[1] {
+		case '3':
+			p.layout.LineSpacing = n
+		case 'J':
+			p.flushLine(n)
+		case 'd':
+			p.flushLine(n * p.layout.LineSpacing)
+		case 'E', 'G':
+			p.bold = n&1 == 1
+		case 'V':
+			p.layout.Rotate = n&1 == 1
+		case 'M':
+			p.layout.Font = n & 1
+		case 'a':
+			p.justify = n % 3
+		}
+		return 3
+	case 'D':
+		for i := 2; i < len(b); i++ {
+			if b[i] == escpos.NUL {
+				p.layout.Tabs = nil
+				for _, t := range b[2:i] {
+					p.layout.Tabs = append(p.layout.Tabs, int(t))
+				}
+				return i + 1
+			}
+		}
+		return 0
+	case '\\':
+		if !need(b, 4) {
+			return 0
+		}
+		p.layout.Move(int(int16(uint16(b[2]) | uint16(b[3])<<8)))
+		return 4
+	case '*':
+		return p.bitImage(b)
+	}
+
+	return skip(b, ignoredESC)
+}
+
+func (p *Printer) executeGS(b []byte) int {
+	if !need(b, 2) {
+		return 0
+	}
+
+	switch b[1] {
+	case 'V':
+		if !need(b, 3) {
+			return 0
+		}
+		if b[2] == 65 || b[2] == 66 {
+			if !need(b, 4) {
+				return 0
+			}
+			p.flushLine(int(b[3]))
+			p.cut()
+			return 4
+		}
+		p.cut()
+		return 3
+	case 'B':
+		if !need(b, 3) {
+			return 0
+		}
+		p.reverse = b[2]&1 == 1
+		return 3
+	case 'H':
+		if !need(b, 3) {
+			return 0
+		}
+		p.layout.HRIPosition = escpos.HRIPosition(b[2] % 4)
+		return 3
+	case 'h':
+		if !need(b, 3) {
+			return 0
+		}
+		p.layout.BarCodeHeight = int(b[2])
+		return 3
+	case 'k':
+		return p.barCode(b)
+	case 'I':
+		if !need(b, 3) {
+			return 0
+		}
+		p.responses = append(p.responses, p.id.Reply(b[2])...)
+		return 3
+	case '(':
+		// GS ( fn pL pH followed by pL + pH*256 bytes
+		if !need(b, 5) {
+			return 0
+		}
+		size := 5 + (int(b[3]) | int(b[4])<<8)
+		if !need(b, size) {
+			return 0
+		}
+		return size
+	case 'v':
+		// GS v 0 m xL xH yL yH followed by the raster image
+		if !need(b, 8) {
+			return 0
+		}
+		size := 8 + (int(b[4])|int(b[5])<<8)*(int(b[6])|int(b[7])<<8)
+		if !need(b, size) {
+			return 0
+		}
+		return size
+	}
+
+	return skip(b, ignoredGS)
+}
+
+// ignoredESC and ignoredGS are the number of parameter bytes of fixed length
+// commands that aren't drawn, so their parameters aren't printed as text
+var (
+	ignoredESC = map[byte]int{
+		' ': 1, '!': 1, '$': 2, '%': 1, '-': 1, '<': 0, '=': 1, '?': 1,
+		'L': 0, 'R': 1, 'S': 0, 'T': 1, 'U': 1, 'W': 8, 'c': 2, 'i': 0,
+		'm': 0, 'p': 3, 'r': 1, 't': 1, '{': 1,
+	}
+	ignoredGS = map[byte]int{
+		'!': 1, '$': 2, ':': 0, 'L': 2, 'P': 2, 'T': 1, '\\': 2, '^': 3,
+		'a': 1, 'b': 1, 'f': 1, 'r': 1, 'w': 1,
+	}
+)
+
+// skip returns the size of a command that isn't drawn.  Unknown commands are
+// assumed to take one parameter like most ESC/POS commands do.
+func skip(b []byte, params map[byte]int) int {
+	n, ok := params[b[1]]
+	if !ok {
+		n = 1
+	}
+	if !need(b, 2+n) {
+		return 0
+	}
+	return 2 + n
+}
+
+func (p *Printer) executeDLE(b []byte) int {
+	if !need(b, 2) {
+		return 0
+	}
+
+	if b[1] != escpos.EOT {
+		return 2
+	}
+
+	if !need(b, 3) {
+		return 0
+	}
+
+	p.responses = append(p.responses, p.status.Reply(b[2]))
+	return 3
+}
+
+// bitImage handles ESC * m nL nH d1...dk
+func (p *Printer) bitImage(b []byte) int {
+	if !need(b, 5) {
+		return 0
+	}
+
+	m := b[2]
+	n := int(b[3]) | int(b[4])<<8
+
+	// Dots per column byte and the size of each printed dot.  Unknown modes
+	// take three bytes per column from 32 up like the 24-dot modes and are
+	// skipped without printing.
+	bytesPerCol, dotHeight, dotWidth := 1, 3, 1
+	if m >= 32 {
+		bytesPerCol = 3
+	}
+
+	size := 5 + n*bytesPerCol
+	if !need(b, size) {
+		return 0
+	}
+
+	switch m {
+	case 0:
+		dotWidth = 2
+	case 1:
+	case 32:
+		dotHeight, dotWidth = 1, 2
+	case 33:
+		dotHeight = 1
+	default:
+		return size
+	}
+
+	data := b[5:size]
+	img := blank(n*dotWidth, bytesPerCol*8*dotHeight)
+	for col := 0; col < n; col++ {
+		for z := 0; z < bytesPerCol; z++ {
+			d := data[col*bytesPerCol+z]
+			for bit := 0; bit < 8; bit++ {
+				if d&(0x80>>bit) == 0 {
+					continue
+				}
+				y := (z*8 + bit) * dotHeight
+				fill(img, image.Rect(col*dotWidth, y, (col+1)*dotWidth, y+dotHeight), color.Gray{})
+			}
+		}
+	}
+
+	p.add(img)
+	return size
+}
+
+// barCode handles both GS k m d1...dk NUL and GS k m n d1...dn
+func (p *Printer) barCode(b []byte) int {
+	if !need(b, 3) {
+		return 0
+	}
+
+	var data []byte
+	var size int
+
+	if b[2] <= 6 {
+		for i := 3; i < len(b); i++ {
+			if b[i] == escpos.NUL {
+				data, size = b[3:i], i+1
+				break
+			}
+		}
+		if size == 0 {
+			return 0
+		}
+	} else {
+		if !need(b, 4) {
+			return 0
+		}
+		size = 4 + int(b[3])
+		if !need(b, size) {
+			return 0
+		}
+		data = b[4:size]
+	}
+
+	p.flushLine(0)
+
+	// Start guard, eight bars for every byte of data and an end guard
+	bits := []bool{true, false, true}
+	for _, d := range data {
+		for bit := 0; bit < 8; bit++ {
+			bits = append(bits, d&(0x80>>bit) != 0)
+		}
+		bits = append(bits, false)
+	}
+	bits = append(bits, true, false, true)
+
+	height := p.layout.BarCodeHeight
+	width := len(bits)*barCodeModule + 2*barCodeQuiet
+	bars := blank(width, height)
+	for i, bar := range bits {
+		if bar {
+			x := barCodeQuiet + i*barCodeModule
+			fill(bars, image.Rect(x, 0, x+barCodeModule, height), color.Gray{})
+		}
+	}
+
+	hri := func() {
+		for _, d := range data {
+			p.character(d)
+		}
+		p.flushLine(p.layout.LineSpacing)
+	}
+
+	hriPosition := p.layout.HRIPosition
+	if hriPosition == escpos.HRIAbove || hriPosition == escpos.HRIBoth {
+		hri()
+	}
+
+	p.add(bars)
+	p.flushLine(0)
+
+	if hriPosition == escpos.HRIBelow || hriPosition == escpos.HRIBoth {
+		hri()
+	}
+
+	return size
+}
+
+// character adds a single character to the line buffer
+func (p *Printer) character(c byte) {
+	img := glyph(c, escpos.Fonts[p.layout.Font], p.bold, p.reverse)
+	if p.layout.Rotate {
+		img = rotate(img)
+	}
+
+	if !p.layout.Fits(img.Bounds().Dx()) {
+		p.flushLine(p.layout.LineSpacing)
+	}
+	p.add(img)
+}
+
+// add places img in the line buffer at the current position
+func (p *Printer) add(img *image.Gray) {
+	x := p.layout.Add(img.Bounds().Dx(), img.Bounds().Dy())
+	p.line = append(p.line, item{x: x, img: img})
+}
+
+// flushLine prints the line buffer and then feeds the paper by at least
+// feed dots or the height of the line, whichever is larger
+func (p *Printer) flushLine(feed int) {
+	height, feed := p.layout.Flush(feed)
+
+	width := 0
+	for _, it := range p.line {
+		if w := it.x + it.img.Bounds().Dx(); w > width {
+			width = w
+		}
+	}
+
+	p.grow(p.y + height)
+
+	offset := 0
+	switch p.justify {
+	case 1:
+		offset = (PaperWidth - width) / 2
+	case 2:
+		offset = PaperWidth - width
+	}
+
+	for _, it := range p.line {
+		// Line up the bottoms of everything in the line
+		r := it.img.Bounds().Add(image.Pt(offset+it.x, p.y+height-it.img.Bounds().Dy()))
+		draw.Draw(p.page, r, it.img, image.Point{}, draw.Src)
+	}
+
+	p.y += feed
+	p.grow(p.y)
+	p.line = nil
+}
+
+// cut finishes the current page.  Nothing happens if the page is blank.
+func (p *Printer) cut() {
+	p.flushLine(0)
+	if p.y == 0 {
+		return
+	}
+
+	p.pages = append(p.pages, crop(p.page, p.y))
+	p.page = nil
+	p.y = 0
+}
+
+// grow makes sure the current page is at least height dots tall
+func (p *Printer) grow(height int) {
+	if p.page != nil && p.page.Bounds().Dy() >= height {
+		return
+	}
+
+	size := 1024
+	if p.page != nil {
+		size = p.page.Bounds().Dy() * 2
+	}
+	for size < height {
+		size *= 2
+	}
+
+	page := blank(PaperWidth, size)
+	if p.page != nil {
+		copy(page.Pix, p.page.Pix)
+	}
+	p.page = page
+}
+
+func crop(page *image.Gray, height int) *image.Gray {
+	img := blank(PaperWidth, height)
+	if page != nil {
+		copy(img.Pix, page.Pix[:len(img.Pix)])
+	}
+	return img
+}
+
+func blank(width, height int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	fill(img, img.Bounds(), color.Gray{Y: 0xFF})
+	return img
+}
+
+func fill(img *image.Gray, r image.Rectangle, c color.Gray) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+type glyphKey struct {
+	c             byte
+	f             escpos.Cell
+	bold, reverse bool
+}
+
+var (
+	glyphMu    sync.Mutex
+	glyphCache = map[glyphKey]*image.Gray{}
+)
+
+// glyph draws c into a character cell of the given font
+//
+// Bytes outside of printable ASCII are drawn as '?'.
+func glyph(c byte, f escpos.Cell, bold, reverse bool) *image.Gray {
+	key := glyphKey{c, f, bold, reverse}
+
+	glyphMu.Lock()
+	defer glyphMu.Unlock()
+
+	if img, ok := glyphCache[key]; ok {
+		return img
+	}
+
+	r := rune(c)
+	if c < 0x20 || c > 0x7E {
+		r = '?'
+	}
+
+	face := basicfont.Face7x13
+	src := image.NewGray(image.Rect(0, 0, face.Advance, face.Height))
+	dr, mask, maskp, _, ok := face.Glyph(fixed.P(0, face.Ascent), r)
+	if ok {
+		draw.DrawMask(src, dr, image.White, image.Point{}, mask, maskp, draw.Over)
+	}
+
+	fg, bg := color.Gray{}, color.Gray{Y: 0xFF}
+	if reverse {
+		fg, bg = bg, fg
+	}
+
+	img := image.NewGray(image.Rect(0, 0, f.Width, f.Height))
+	fill(img, img.Bounds(), bg)
+
+	// Scale the glyph to the cell leaving a one dot border
+	w, h := f.Width-2, f.Height-2
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if src.GrayAt(x*face.Advance/w, y*face.Height/h).Y < 0x80 {
+				continue
+			}
+			img.SetGray(x+1, y+1, fg)
+			if bold {
+				img.SetGray(x+2, y+1, fg)
+			}
+		}
+	}
+
+	glyphCache[key] = img
+	return img
+}
+
+// rotate returns img turned 90 degrees clockwise
+func rotate(img *image.Gray) *image.Gray {
+	b := img.Bounds()
+	out := image.NewGray(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			out.SetGray(b.Dy()-1-y, x, img.GrayAt(x, y))
+		}
+	}
+	return out
+}
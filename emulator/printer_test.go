@@ -0,0 +1,295 @@
+package emulator_test
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/joeyak/hoin-printer/emulator"
+	"github.com/joeyak/hoin-printer/escpos"
+)
+
+const (
+	ESC = escpos.ESC
+	GS  = escpos.GS
+	LF  = escpos.LF
+	NUL = escpos.NUL
+)
+
+// render writes every command to a new printer
+func render(t *testing.T, commands ...[]byte) *emulator.Printer {
+	t.Helper()
+
+	p := emulator.New()
+	_, err := p.Write(bytes.Join(commands, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// page returns the only page printed
+func page(t *testing.T, p *emulator.Printer) *image.Gray {
+	t.Helper()
+
+	pages := p.Pages()
+	if len(pages) != 1 {
+		t.Fatalf("expected one page but got %d", len(pages))
+	}
+	return pages[0]
+}
+
+// inked returns the bounds of the black dots on img
+func inked(img *image.Gray) image.Rectangle {
+	var r image.Rectangle
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if img.GrayAt(x, y).Y < 0x80 {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+// dots counts the black dots on img
+func dots(img *image.Gray) int {
+	n := 0
+	for _, v := range img.Pix {
+		if v < 0x80 {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRenderText(t *testing.T) {
+	p := render(t, []byte("Hi\n"))
+
+	img := page(t, p)
+	if img.Bounds() != image.Rect(0, 0, emulator.PaperWidth, 30) {
+		t.Errorf("expected one line of paper but got %v", img.Bounds())
+	}
+	if ink := inked(img); ink.Empty() || !ink.In(image.Rect(0, 0, 24, 24)) {
+		t.Errorf("expected two characters in the top left but ink was at %v", ink)
+	}
+	if p.Fed() != 30 {
+		t.Errorf("expected 30 dots fed but got %d", p.Fed())
+	}
+}
+
+func TestRenderWrap(t *testing.T) {
+	// 48 Font A characters fit on a line
+	p := render(t, bytes.Repeat([]byte("H"), 49), []byte{LF})
+	if p.Fed() != 60 {
+		t.Errorf("expected the text to wrap onto a second line but %d dots were fed", p.Fed())
+	}
+}
+
+func TestRenderFonts(t *testing.T) {
+	fontA := inked(page(t, render(t, []byte("HHHH\n"))))
+	fontB := inked(page(t, render(t, []byte{ESC, 'M', 1}, []byte("HHHH\n"))))
+
+	if fontA.Max.X > 48 || fontA.Max.X <= 36 {
+		t.Errorf("expected four 12 dot wide Font A characters but ink was at %v", fontA)
+	}
+	if fontB.Max.X > 36 {
+		t.Errorf("expected four 9 dot wide Font B characters but ink was at %v", fontB)
+	}
+	if fontB.Dy() >= fontA.Dy() {
+		t.Errorf("expected Font B to be shorter than Font A but got %v and %v", fontB, fontA)
+	}
+}
+
+func TestRenderBoldAndReverse(t *testing.T) {
+	regular := page(t, render(t, []byte("H\n")))
+	bold := page(t, render(t, []byte{ESC, 'E', 1}, []byte("H\n")))
+	reverse := page(t, render(t, []byte{GS, 'B', 1}, []byte("H\n")))
+
+	if dots(bold) <= dots(regular) {
+		t.Errorf("expected bold to use more dots than regular but got %d and %d", dots(bold), dots(regular))
+	}
+	if reverse.GrayAt(0, 0).Y >= 0x80 || reverse.GrayAt(11, 23).Y >= 0x80 {
+		t.Error("expected the character cell to be black when reversed")
+	}
+}
+
+func TestRenderRotate(t *testing.T) {
+	ink := inked(page(t, render(t, []byte{ESC, 'V', 1}, []byte("H\n"))))
+	if ink.Dx() <= ink.Dy() {
+		t.Errorf("expected a rotated character to be wider than tall but ink was at %v", ink)
+	}
+}
+
+func TestRenderJustify(t *testing.T) {
+	tests := []struct {
+		name     string
+		n        byte
+		min, max int
+	}{
+		{"Left", 0, 0, 12},
+		{"Center", 1, 282, 294},
+		{"Right", 2, 564, 576},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ink := inked(page(t, render(t, []byte{ESC, 'a', tt.n}, []byte("H\n"))))
+			if ink.Min.X < tt.min || ink.Max.X > tt.max {
+				t.Errorf("expected ink between %d and %d but it was at %v", tt.min, tt.max, ink)
+			}
+		})
+	}
+}
+
+func TestRenderTabs(t *testing.T) {
+	ink := inked(page(t, render(t, []byte{ESC, 'D', 10, NUL}, []byte("\tH\n"))))
+	if ink.Min.X < 120 || ink.Max.X > 132 {
+		t.Errorf("expected the character at the tab stop at 120 dots but ink was at %v", ink)
+	}
+}
+
+func TestRenderBitImage(t *testing.T) {
+	tests := []struct {
+		name  string
+		m     byte
+		width int
+	}{
+		{"8DotSingle", 0, 20},
+		{"8DotDouble", 1, 10},
+		{"24DotSingle", 32, 20},
+		{"24DotDouble", 33, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bytesPerCol := 1
+			if tt.m >= 32 {
+				bytesPerCol = 3
+			}
+
+			cmd := []byte{ESC, '*', tt.m, 10, 0}
+			cmd = append(cmd, bytes.Repeat([]byte{0xFF}, 10*bytesPerCol)...)
+			img := page(t, render(t, cmd, []byte{LF}))
+
+			if ink := inked(img); ink != image.Rect(0, 0, tt.width, 24) {
+				t.Errorf("expected a black band %d dots wide but ink was at %v", tt.width, ink)
+			}
+			if dots(img) != tt.width*24 {
+				t.Errorf("expected the band to be solid but got %d dots", dots(img))
+			}
+		})
+	}
+}
+
+func TestRenderBarCode(t *testing.T) {
+	barCode := append([]byte{GS, 'k', 2}, "4901234567894"...)
+	barCode = append(barCode, NUL)
+
+	p := render(t, []byte{GS, 'h', 50}, barCode)
+	img := page(t, p)
+	if p.Fed() != 50 || img.Bounds().Dy() != 50 {
+		t.Errorf("expected a 50 dot tall bar code but fed %d dots", p.Fed())
+	}
+	if ink := inked(img); ink.Dy() != 50 || ink.Dx() < 100 {
+		t.Errorf("expected bars the height of the bar code but ink was at %v", ink)
+	}
+
+	p = render(t, []byte{GS, 'h', 50}, []byte{GS, 'H', 2}, barCode)
+	if p.Fed() != 80 {
+		t.Errorf("expected a line of text below the bar code but fed %d dots", p.Fed())
+	}
+}
+
+func TestRenderUnknownBarCode(t *testing.T) {
+	// Unknown types are skipped by their length like the known ones
+	p := render(t, []byte{GS, 'h', 50}, []byte{GS, 'k', 100, 3, 'A', 'B', 'C'}, []byte{LF})
+	if ink := inked(page(t, p)); ink.Max.Y > 50 {
+		t.Errorf("expected nothing to be printed below the bar code but ink was at %v", ink)
+	}
+}
+
+func TestRenderFeed(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []byte
+		fed      int
+	}{
+		{"ESC J", []byte{ESC, 'J', 100}, 100},
+		{"ESC d", []byte{ESC, 'd', 2}, 60},
+		{"ESC 3", []byte{ESC, '3', 40, LF}, 40},
+		{"ESC 2", []byte{ESC, '3', 40, ESC, '2', LF}, 30},
+		{"ESC @", []byte{ESC, '3', 40, ESC, '@', LF}, 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := render(t, tt.commands)
+			if p.Fed() != tt.fed {
+				t.Errorf("expected %d dots fed but got %d", tt.fed, p.Fed())
+			}
+		})
+	}
+}
+
+func TestRenderCut(t *testing.T) {
+	p := render(t,
+		[]byte("A\n"), []byte{GS, 'V', 0},
+		// Cutting a blank page doesn't make another
+		[]byte{GS, 'V', 0},
+		[]byte("B\n"), []byte{GS, 'V', 65, 20},
+		[]byte("C\n"),
+	)
+
+	pages := p.Pages()
+	if len(pages) != 3 {
+		t.Fatalf("expected three pages but got %d", len(pages))
+	}
+	heights := []int{30, 50, 30}
+	for i, page := range pages {
+		if page.Bounds().Dy() != heights[i] {
+			t.Errorf("expected page %d to be %d dots tall but got %d", i+1, heights[i], page.Bounds().Dy())
+		}
+	}
+	if p.Fed() != 110 {
+		t.Errorf("expected 110 dots fed but got %d", p.Fed())
+	}
+}
+
+func TestRenderSplitWrites(t *testing.T) {
+	// Commands split across writes are kept until they are complete
+	p := emulator.New()
+	for _, b := range []byte{ESC, 'J', 100} {
+		p.Write([]byte{b})
+	}
+	if p.Fed() != 100 {
+		t.Errorf("expected 100 dots fed but got %d", p.Fed())
+	}
+}
+
+func TestRenderIgnoredCommands(t *testing.T) {
+	tests := []struct {
+		name    string
+		command []byte
+	}{
+		{"ESC t", []byte{ESC, 't', '1'}},
+		{"ESC !", []byte{ESC, '!', 'A'}},
+		{"ESC p", []byte{ESC, 'p', 0, 'A', 'B'}},
+		{"GS !", []byte{GS, '!', 'A'}},
+		{"GS (", []byte{GS, '(', 'k', 3, 0, 'A', 'B', 'C'}},
+		{"GS v 0", []byte{GS, 'v', '0', 0, 2, 0, 2, 0, 'A', 'B', 'C', 'D'}},
+		{"ESC * unknown mode", []byte{ESC, '*', 2, 2, 0, 'A', 'B'}},
+		{"ESC * unknown 24-dot mode", []byte{ESC, '*', 34, 1, 0, 'A', 'B', 'C'}},
+		{"Unknown", []byte{ESC, 'y', 'A'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := render(t, tt.command, []byte{LF})
+			if n := dots(page(t, p)); n != 0 {
+				t.Errorf("expected the parameters not to be printed but got %d dots", n)
+			}
+		})
+	}
+}
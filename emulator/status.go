@@ -0,0 +1,58 @@
+package emulator
+
+// Status is the printer state reported by real-time status replies
+type Status struct {
+	// Printer status (DLE EOT 1)
+	DrawerOpen bool
+	Offline    bool
+
+	// Offline status (DLE EOT 2)
+	CoverOpen       bool
+	FeedButton      bool
+	PrintingStopped bool
+	ErrorOccurred   bool
+
+	// Error status (DLE EOT 3)
+	CutterError          bool
+	UnrecoverableError   bool
+	AutoRecoverableError bool
+
+	// Paper sensor status (DLE EOT 4)
+	PaperNearEnd bool
+	PaperOut     bool
+}
+
+func setBits(b *byte, on bool, bits byte) {
+	if on {
+		*b |= bits
+	}
+}
+
+// Reply returns the byte sent in reply to DLE EOT n
+//
+// Every reply has bits 1 and 4 set.  Replies to unknown values of n only
+// have the fixed bits.
+func (s Status) Reply(n byte) byte {
+	b := byte(0b0001_0010)
+
+	switch n {
+	case 1:
+		setBits(&b, s.DrawerOpen, 0b0000_0100)
+		setBits(&b, s.Offline, 0b0000_1000)
+	case 2:
+		setBits(&b, s.CoverOpen, 0b0000_0100)
+		setBits(&b, s.FeedButton, 0b0000_1000)
+		// Printing stops when the paper runs out
+		setBits(&b, s.PrintingStopped || s.PaperOut, 0b0010_0000)
+		setBits(&b, s.ErrorOccurred, 0b0100_0000)
+	case 3:
+		setBits(&b, s.CutterError, 0b0000_1000)
+		setBits(&b, s.UnrecoverableError, 0b0010_0000)
+		setBits(&b, s.AutoRecoverableError, 0b0100_0000)
+	case 4:
+		setBits(&b, s.PaperNearEnd, 0b0000_1100)
+		setBits(&b, s.PaperOut, 0b0110_0000)
+	}
+
+	return b
+}
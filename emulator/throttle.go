@@ -0,0 +1,237 @@
+package emulator
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/joeyak/hoin-printer/escpos"
+)
+
+const (
+	XON  = 0x11
+	XOFF = 0x13
+)
+
+// ThrottleOptions describes the limits of the printer hardware
+type ThrottleOptions struct {
+	// BufferSize is the size of the receive buffer in bytes.  Bytes that
+	// arrive while the buffer is full are dropped.  0 means unlimited.
+	BufferSize int
+	// Speed is how fast paper moves while printing in mm/s.  0 prints
+	// instantly.
+	Speed float64
+	// XonXoff sends XOFF when the buffer is three quarters full and XON once
+	// it is down to a quarter.  It needs a BufferSize.
+	XonXoff bool
+}
+
+// ThrottleStats counts what happened to the bytes sent to a Throttled
+type ThrottleStats struct {
+	Received int
+	Dropped  int
+	// Buffered is the number of bytes waiting to be printed
+	Buffered int
+	// MaxBuffered is the most bytes that were waiting at once
+	MaxBuffered int
+}
+
+// Throttled wraps a Printer with a finite receive buffer and a print speed
+//
+// Bytes wait in the buffer while the paper is moving and are dropped when
+// the buffer is full, like real hardware without flow control.  Status
+// replies are only sent once every byte before the request has been
+// printed, which is what the hoin package relies on to avoid overflowing
+// the buffer.
+type Throttled struct {
+	printer *Printer
+	opts    ThrottleOptions
+
+	mu        sync.Mutex
+	queue     []byte
+	busyUntil time.Time
+	stats     ThrottleStats
+	paused    bool
+	flow      []byte
+	// wake is closed when a flow control byte is sent or the deadline
+	// changes so a waiting Read notices straight away
+	wake     chan struct{}
+	deadline time.Time
+}
+
+// NewThrottled limits p to the given buffer size and speed
+func NewThrottled(p *Printer, opts ThrottleOptions) *Throttled {
+	return &Throttled{
+		printer: p,
+		opts:    opts,
+		wake:    make(chan struct{}),
+	}
+}
+
+// Printer returns the wrapped printer
+func (t *Throttled) Printer() *Printer {
+	return t.printer
+}
+
+// Stats returns the counts so far
+func (t *Throttled) Stats() ThrottleStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.catchUp(time.Now())
+	stats := t.stats
+	stats.Buffered = len(t.queue)
+	return stats
+}
+
+// catchUp prints every buffered byte the printer would have got to by now
+func (t *Throttled) catchUp(now time.Time) {
+	for len(t.queue) > 0 && !t.busyUntil.After(now) {
+		t.printer.mu.Lock()
+		before := t.printer.layout.Fed
+		t.printer.mu.Unlock()
+
+		t.printer.Write(t.queue[:1])
+		t.queue = t.queue[1:]
+
+		t.printer.mu.Lock()
+		fed := t.printer.layout.Fed - before
+		t.printer.mu.Unlock()
+
+		if fed > 0 && t.opts.Speed > 0 {
+			seconds := float64(fed) / escpos.DotsPerMM / t.opts.Speed
+			t.busyUntil = t.busyUntil.Add(time.Duration(seconds * float64(time.Second)))
+		}
+
+		if t.paused && len(t.queue) <= t.opts.BufferSize/4 {
+			t.paused = false
+			t.sendFlow(XON)
+		}
+	}
+}
+
+// sendFlow queues a flow control byte to be read
+func (t *Throttled) sendFlow(c byte) {
+	t.flow = append(t.flow, c)
+	t.wakeRead()
+}
+
+// Write adds b to the receive buffer, dropping anything that doesn't fit
+//
+// The full length of b is always reported as written since the printer has
+// no way to tell the sender about dropped bytes.
+func (t *Throttled) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.catchUp(now)
+	if len(t.queue) == 0 && t.busyUntil.Before(now) {
+		t.busyUntil = now
+	}
+
+	t.stats.Received += len(b)
+
+	accepted := b
+	if t.opts.BufferSize > 0 {
+		free := t.opts.BufferSize - len(t.queue)
+		if free < len(accepted) {
+			accepted = accepted[:free]
+		}
+	}
+	t.stats.Dropped += len(b) - len(accepted)
+
+	t.queue = append(t.queue, accepted...)
+	if len(t.queue) > t.stats.MaxBuffered {
+		t.stats.MaxBuffered = len(t.queue)
+	}
+
+	if t.opts.XonXoff && t.opts.BufferSize > 0 && !t.paused && len(t.queue) >= t.opts.BufferSize*3/4 {
+		t.paused = true
+		t.sendFlow(XOFF)
+	}
+
+	t.catchUp(now)
+	// Replies to anything printed straight away can be read now
+	t.wakeRead()
+	return len(b), nil
+}
+
+// Drain waits until every buffered byte has been printed
+func (t *Throttled) Drain() {
+	for {
+		t.mu.Lock()
+		now := time.Now()
+		t.catchUp(now)
+		if len(t.queue) == 0 {
+			t.mu.Unlock()
+			return
+		}
+		wait := t.busyUntil.Sub(now)
+		t.mu.Unlock()
+
+		time.Sleep(wait)
+	}
+}
+
+// SetReadDeadline makes Read return os.ErrDeadlineExceeded when it is
+// still waiting at t.  The zero time waits forever.
+func (t *Throttled) SetReadDeadline(deadline time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.deadline = deadline
+	t.wakeRead()
+	return nil
+}
+
+// wakeRead wakes a waiting Read.  t.mu must be held.
+func (t *Throttled) wakeRead() {
+	close(t.wake)
+	t.wake = make(chan struct{})
+}
+
+// Read returns status replies once every byte sent before the request has
+// been printed, waiting for the buffer to drain that far if needed.  XON and
+// XOFF are returned as soon as they are sent.
+//
+// io.EOF is returned when there is nothing to read, unless a read deadline
+// is set.  Then Read waits for a reply until the deadline, like a net.Conn.
+func (t *Throttled) Read(b []byte) (int, error) {
+	for {
+		t.mu.Lock()
+		now := time.Now()
+		t.catchUp(now)
+
+		if len(t.flow) > 0 {
+			n := copy(b, t.flow)
+			t.flow = t.flow[n:]
+			t.mu.Unlock()
+			return n, nil
+		}
+
+		n, err := t.printer.Read(b)
+		if n > 0 || (len(t.queue) == 0 && t.deadline.IsZero()) {
+			t.mu.Unlock()
+			return n, err
+		}
+
+		if !t.deadline.IsZero() && !now.Before(t.deadline) {
+			t.mu.Unlock()
+			return 0, os.ErrDeadlineExceeded
+		}
+
+		wait := t.deadline.Sub(now)
+		if len(t.queue) > 0 && (t.deadline.IsZero() || t.busyUntil.Sub(now) < wait) {
+			wait = t.busyUntil.Sub(now)
+		}
+		wake := t.wake
+		t.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-wake:
+			timer.Stop()
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package emulator_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/joeyak/hoin-printer"
+	"github.com/joeyak/hoin-printer/emulator"
+	"github.com/joeyak/hoin-printer/escpos"
+)
+
+func TestThrottledDropsOverflow(t *testing.T) {
+	// Each line feed is 30 dots or 3.75mm which takes 37.5ms at 100mm/s
+	throttled := emulator.NewThrottled(emulator.New(), emulator.ThrottleOptions{
+		BufferSize: 50,
+		Speed:      100,
+	})
+
+	throttled.Write(bytes.Repeat([]byte{escpos.LF}, 200))
+
+	// Only 50 bytes fit in the buffer and the paper is still moving
+	stats := throttled.Stats()
+	if stats.Dropped != 150 {
+		t.Errorf("expected 150 dropped bytes but got %d", stats.Dropped)
+	}
+	if stats.MaxBuffered != 50 {
+		t.Errorf("expected at most 50 buffered bytes but got %d", stats.MaxBuffered)
+	}
+}
+
+func TestThrottledDelaysStatus(t *testing.T) {
+	throttled := emulator.NewThrottled(emulator.New(), emulator.ThrottleOptions{
+		BufferSize: 100,
+		Speed:      100,
+	})
+
+	start := time.Now()
+	throttled.Write([]byte{escpos.LF, escpos.LF, escpos.LF, escpos.LF, escpos.DLE, escpos.EOT, 3})
+
+	reply := make([]byte, 1)
+	n, err := throttled.Read(reply)
+	if err != nil || n != 1 {
+		t.Fatalf("expected a status reply but got %d bytes and %v", n, err)
+	}
+
+	// Four line feeds at 37.5ms each
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("status was sent after %s before the buffer was printed", elapsed)
+	}
+}
+
+func TestThrottledStatusPolling(t *testing.T) {
+	// A buffer smaller than two bands of a 24-dot image at full width
+	throttled := emulator.NewThrottled(emulator.New(), emulator.ThrottleOptions{
+		BufferSize: 3000,
+		Speed:      2000,
+	})
+
+	img := image.NewGray(image.Rect(0, 0, emulator.PaperWidth, 24*5))
+	err := hoin.NewPrinter(throttled).PrintImage24(img, hoin.DoubleDensity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := throttled.Stats()
+	if stats.Dropped != 0 {
+		t.Errorf("polling status after each band still dropped %d bytes", stats.Dropped)
+	}
+
+	throttled.Drain()
+	pages := throttled.Printer().Pages()
+	if len(pages) != 1 || pages[0].Bounds().Dy() != 24*5 {
+		t.Errorf("expected one page 120 dots tall but got %d pages", len(pages))
+	}
+}
+
+func TestThrottledReadDeadline(t *testing.T) {
+	throttled := emulator.NewThrottled(emulator.New(), emulator.ThrottleOptions{})
+
+	reply := make([]byte, 1)
+	_, err := throttled.Read(reply)
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("expected nothing to read without a deadline but got %v", err)
+	}
+
+	// With a deadline Read waits for the request to be written
+	throttled.SetReadDeadline(time.Now().Add(time.Minute))
+	go throttled.Write([]byte{escpos.DLE, escpos.EOT, 1})
+	n, err := throttled.Read(reply)
+	if err != nil || n != 1 {
+		t.Fatalf("expected a status reply but got %d bytes and %v", n, err)
+	}
+
+	// Moving the deadline up stops a waiting Read
+	go throttled.SetReadDeadline(time.Now())
+	_, err = throttled.Read(reply)
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("expected the deadline to pass but got %v", err)
+	}
+}
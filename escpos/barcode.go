@@ -0,0 +1,172 @@
+package escpos
+
+import (
+	"strings"
+)
+
+type BarCode int
+
+const (
+	BcUPCA BarCode = iota
+	BcUPCE
+	BcJAN13
+	BcJAN8
+	BcCODE39
+	BcITF
+	BcCODABAR
+	BcCODE93  BarCode = 72
+	BcCODE128 BarCode = 73
+
+	// Deprecated: BcCODE123 is a misspelling of BcCODE128
+	BcCODE123 = BcCODE128
+)
+
+var (
+	lengthBarcodes = []BarCode{BcCODE93, BcCODE128}
+	allBarcodes    = append(lengthBarcodes, BcUPCA, BcUPCE, BcJAN13, BcJAN8, BcCODE39, BcITF, BcCODABAR)
+)
+
+// AppendSetHRIPosition appends the printing position of the HRI characters
+// in relation to the barcode
+func AppendSetHRIPosition(buf []byte, hp HRIPosition) ([]byte, error) {
+	err := checkEnum(hp, HRINone, HRIAbove, HRIBelow, HRIBoth)
+	if err != nil {
+		return buf, err
+	}
+	return append(buf, GS, 'H', byte(hp)), nil
+}
+
+// AppendResetBarCodeHeight appends a bar code height of 162
+func AppendResetBarCodeHeight(buf []byte) []byte {
+	buf, _ = AppendSetBarCodeHeight(buf, 162)
+	return buf
+}
+
+// AppendSetBarCodeHeight appends a bar code height of n dots
+func AppendSetBarCodeHeight(buf []byte, n int) ([]byte, error) {
+	err := checkRange(n, 1, 255, "height")
+	if err != nil {
+		return buf, err
+	}
+	return append(buf, GS, 'h', byte(n)), nil
+}
+
+func checkBarcodeCodabarData(data string) error {
+	body := "0123456789-$:/.+"
+	wrappers := "ABCD"
+
+	runes := []rune(data)
+	last := len(runes) - 1
+	for i, r := range runes {
+		accepted := body
+		if i == 0 || i == last {
+			accepted = wrappers
+		}
+		if !strings.ContainsRune(accepted, r) {
+			return &BarcodeDataError{Position: i, Rune: r, Accepted: accepted}
+		}
+	}
+
+	return nil
+}
+
+func checkBarcodeData(data, accepted string) error {
+	for i, r := range []rune(data) {
+		if !strings.ContainsRune(accepted, r) {
+			return &BarcodeDataError{Position: i, Rune: r, Accepted: accepted}
+		}
+	}
+	return nil
+}
+
+// CheckBarCode reports whether data can be printed as barcodeType
+//
+// The size ranges are as follows in (Type: min, max):
+//
+//	BcUPCA: 11, 12
+//	BcUPCE: 6, 7
+//	BcJAN13: 12, 13
+//	BcJAN8: 7, 8
+//	BcCODE39: 0, 14
+//	BcITF: 0, 22
+//	BcCODABAR: 2, 19
+//	BcCODE93: 1, 17
+//	BcCODE128: 0, 60
+//
+// For the accepted data values:
+//
+//	BcUPCA, BcUPCE, BcJAN13, BcJAN8, BcITF all only accept [0123456789]
+//	BcCODE39, BcCODE93, BcCODE128 can accept [ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-.*$/+% ]
+//	BcCODABAR:
+//	  The first and last character of the CODABAR code bar has to be one of [ABCD]
+//	  and the rest of the characters in between can be one of [0123456789-$:/.+]
+func CheckBarCode(barcodeType BarCode, data string) error {
+	err := checkEnum(barcodeType, allBarcodes...)
+	if err != nil {
+		return err
+	}
+
+	// Check length
+	var min, max int
+	switch barcodeType {
+	case BcUPCA:
+		min, max = 11, 12
+	case BcUPCE:
+		min, max = 6, 7
+	case BcJAN13:
+		min, max = 12, 13
+	case BcJAN8:
+		min, max = 7, 8
+	case BcCODE39:
+		min, max = 0, 14
+	case BcITF:
+		min, max = 0, 22
+	case BcCODABAR:
+		min, max = 2, 19
+	case BcCODE93:
+		min, max = 1, 17
+	case BcCODE128:
+		// At 66 characters for 'A...' the printer seems to cry
+		// for printing all 0s it cried at 65
+		// maybe it needs some friends
+		min, max = 0, 60
+	}
+
+	err = checkRange(len(data), min, max, "data length")
+	if err != nil {
+		return err
+	}
+
+	// Check data ranges
+	switch barcodeType {
+	case BcUPCA, BcUPCE, BcJAN13, BcJAN8, BcITF:
+		return checkBarcodeData(data, "0123456789")
+	case BcCODE39, BcCODE93, BcCODE128:
+		return checkBarcodeData(data, "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-.*$/+% ")
+	case BcCODABAR:
+		return checkBarcodeCodabarData(data)
+	}
+	return nil
+}
+
+// AppendBarCode appends the bar code passed in with data after checking it
+// with CheckBarCode
+func AppendBarCode(buf []byte, barcodeType BarCode, data string) ([]byte, error) {
+	err := CheckBarCode(barcodeType, data)
+	if err != nil {
+		return buf, err
+	}
+
+	buf = append(buf, GS, 'k', byte(barcodeType))
+
+	// Add data
+	if inSlice(barcodeType, lengthBarcodes...) {
+		// length defined barcode
+		buf = append(buf, byte(len(data)))
+		return append(buf, data...), nil
+	}
+
+	// Null ending barcode
+	buf = append(buf, data...)
+	return append(buf, NUL), nil
+}
@@ -0,0 +1,46 @@
+package escpos
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrPaperOut is reported when the paper roll has run out
+	ErrPaperOut = errors.New("printer is out of paper")
+	// ErrCoverOpen is reported when the cover of the printer is open
+	ErrCoverOpen = errors.New("printer cover is open")
+)
+
+// RangeError is a number outside of the values a command accepts
+type RangeError struct {
+	Field    string
+	Min, Max int
+	Value    int
+}
+
+func (e *RangeError) Error() string {
+	return fmt.Sprintf("%s must be between %d and %d but was %d", e.Field, e.Min, e.Max, e.Value)
+}
+
+// EnumError is a value that isn't one of the choices a command accepts
+type EnumError struct {
+	Value   int
+	Choices []int
+}
+
+func (e *EnumError) Error() string {
+	return fmt.Sprintf("%d was not a valid choice from %v", e.Value, e.Choices)
+}
+
+// BarcodeDataError is a character that can't be printed in a bar code.
+// Position is the index of the rune in the bar code data.
+type BarcodeDataError struct {
+	Position int
+	Rune     rune
+	Accepted string
+}
+
+func (e *BarcodeDataError) Error() string {
+	return fmt.Sprintf("%q at position %d was in the bar code data and only %q is accepted", e.Rune, e.Position, e.Accepted)
+}
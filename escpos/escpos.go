@@ -0,0 +1,256 @@
+// Package escpos encodes ESC/POS commands without talking to a printer.
+//
+// Every command has an Append function that appends its bytes to a buffer
+// and returns the extended buffer, like strconv.AppendInt.  Commands with
+// parameters validate them first and return the buffer unchanged with an
+// error when they are out of range, so a job can be built up and stored or
+// sent later:
+//
+//	buf := escpos.AppendInitialize(nil)
+//	buf = escpos.AppendSetBold(buf, true)
+//	buf = append(buf, "Hello World!\n"...)
+//	buf, err := escpos.AppendBarCode(buf, escpos.BcJAN13, "4901234567894")
+//
+// The hoin package sends the same bytes to a live printer.
+package escpos
+
+import (
+	"fmt"
+)
+
+const (
+	HT  = 0x09
+	LF  = 0x0A
+	CR  = 0x0D
+	GS  = 0x1D
+	ESC = 0x1B
+	DLE = 0x10
+	EOT = 0x04
+	NUL = 0x00
+)
+
+// DotsPerMM is the resolution of the print head
+const DotsPerMM = 8
+
+type Font int
+
+const (
+	FontA Font = iota
+	FontB
+)
+
+type Justification int
+
+const (
+	LeftJustify Justification = iota
+	CenterJustify
+	RightJustify
+)
+
+type HRIPosition int
+
+const (
+	HRINone HRIPosition = iota
+	HRIAbove
+	HRIBelow
+	HRIBoth
+)
+
+// Density represents the DPI to use when printing images.
+type Density int
+
+const (
+	// SingleDensity is 90dpi
+	SingleDensity Density = iota
+	// DoubleDensity is 180dpi
+	DoubleDensity
+)
+
+func inSlice[T ~int](v T, s ...T) bool {
+	for _, a := range s {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
+
+func checkEnum[T ~int](e T, enums ...T) error {
+	if inSlice(e, enums...) {
+		return nil
+	}
+
+	choices := make([]int, len(enums))
+	for i, a := range enums {
+		choices[i] = int(a)
+	}
+	return &EnumError{Value: int(e), Choices: choices}
+}
+
+func checkRange(n, min, max int, info string) error {
+	if n < min || max < n {
+		return &RangeError{Field: info, Min: min, Max: max, Value: n}
+	}
+	return nil
+}
+
+func boolToByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// AppendInitialize appends ESC @ which resets the printer to its defaults
+func AppendInitialize(buf []byte) []byte {
+	return append(buf, ESC, '@')
+}
+
+// AppendBeep appends a beep of n times for t duration
+//
+// Duration is dependent on the model. For the HOP-E802
+// each duration is around 100ms
+func AppendBeep(buf []byte, n, t int) ([]byte, error) {
+	err := checkRange(n, 1, 9, "n")
+	if err != nil {
+		return buf, err
+	}
+
+	err = checkRange(t, 1, 9, "t")
+	if err != nil {
+		return buf, err
+	}
+
+	return append(buf, ESC, 'B', byte(n), byte(t)), nil
+}
+
+// AppendHT appends a move to the next horizontal tab position
+func AppendHT(buf []byte) []byte {
+	return append(buf, HT)
+}
+
+// AppendLF appends a print and line feed
+func AppendLF(buf []byte) []byte {
+	return append(buf, LF)
+}
+
+// AppendCR appends a print and carriage return
+func AppendCR(buf []byte) []byte {
+	return append(buf, CR)
+}
+
+// AppendCut appends a paper cut
+func AppendCut(buf []byte) []byte {
+	return append(buf, GS, 'V', 0)
+}
+
+// AppendCutFeed appends a feed of n units followed by a cut
+func AppendCutFeed(buf []byte, n int) ([]byte, error) {
+	err := checkRange(n, 0, 255, "n")
+	if err != nil {
+		return buf, err
+	}
+	return append(buf, GS, 'V', 66, byte(n)), nil
+}
+
+// AppendResetLineSpacing appends a reset to the default line spacing of
+// 1/6-inch lines (approx. 4.23mm)
+func AppendResetLineSpacing(buf []byte) []byte {
+	return append(buf, ESC, '2')
+}
+
+// AppendSetLineSpacing appends a line spacing of n * v/h motion units in
+// inches
+func AppendSetLineSpacing(buf []byte, n int) ([]byte, error) {
+	err := checkRange(n, 0, 255, "n")
+	if err != nil {
+		return buf, err
+	}
+	return append(buf, ESC, '3', byte(n)), nil
+}
+
+// AppendFeed appends a paper feed of n units
+func AppendFeed(buf []byte, n int) ([]byte, error) {
+	err := checkRange(n, 0, 255, "n")
+	if err != nil {
+		return buf, err
+	}
+	return append(buf, ESC, 'J', byte(n)), nil
+}
+
+// AppendFeedLines appends a paper feed of n lines
+func AppendFeedLines(buf []byte, n int) ([]byte, error) {
+	err := checkRange(n, 0, 255, "n")
+	if err != nil {
+		return buf, err
+	}
+	return append(buf, ESC, 'd', byte(n)), nil
+}
+
+// AppendSetHT appends the horizontal tab positions
+//
+// A max of 32 positions can be set and no positions resets them.
+func AppendSetHT(buf []byte, positions ...int) ([]byte, error) {
+	err := checkRange(len(positions), 0, 32, "number of positions")
+	if err != nil {
+		return buf, err
+	}
+
+	for i, pos := range positions {
+		err = checkRange(pos, 1, 255, fmt.Sprintf("position %d", i))
+		if err != nil {
+			return buf, err
+		}
+	}
+
+	buf = append(buf, ESC, 'D')
+	for _, pos := range positions {
+		buf = append(buf, byte(pos))
+	}
+	return append(buf, NUL), nil
+}
+
+// AppendSetTabs appends up to 32 tab positions at the given width
+// intervals.  If the tab value exceeds 256, fewer than 32 positions will be
+// set.
+func AppendSetTabs(buf []byte, width int) ([]byte, error) {
+	tabs := []int{}
+	for i := width; i < 256 && len(tabs) < 32; i += width {
+		tabs = append(tabs, i)
+	}
+	return AppendSetHT(buf, tabs...)
+}
+
+// AppendSetBold appends emphasized mode on or off
+func AppendSetBold(buf []byte, b bool) []byte {
+	return append(buf, ESC, 'E', boolToByte(b))
+}
+
+// AppendSetRotate90 appends 90 degree clockwise rotation mode on or off
+func AppendSetRotate90(buf []byte, b bool) []byte {
+	return append(buf, ESC, 'V', boolToByte(b))
+}
+
+// AppendSetReversePrinting appends white/black reverse printing mode on or
+// off
+func AppendSetReversePrinting(buf []byte, b bool) []byte {
+	return append(buf, GS, 'B', boolToByte(b))
+}
+
+// AppendSetFont appends a font change
+func AppendSetFont(buf []byte, f Font) ([]byte, error) {
+	err := checkEnum(f, FontA, FontB)
+	if err != nil {
+		return buf, err
+	}
+	return append(buf, ESC, 'M', byte(f)), nil
+}
+
+// AppendJustify appends the alignment
+func AppendJustify(buf []byte, j Justification) ([]byte, error) {
+	err := checkEnum(j, CenterJustify, LeftJustify, RightJustify)
+	if err != nil {
+		return buf, err
+	}
+	return append(buf, ESC, 'a', byte(j)), nil
+}
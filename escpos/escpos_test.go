@@ -0,0 +1,139 @@
+package escpos_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"testing"
+
+	"github.com/joeyak/hoin-printer/escpos"
+)
+
+func TestAppend(t *testing.T) {
+	buf := escpos.AppendInitialize(nil)
+	buf = escpos.AppendSetBold(buf, true)
+	buf, err := escpos.AppendJustify(buf, escpos.CenterJustify)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf = append(buf, "Hi"...)
+	buf = escpos.AppendLF(buf)
+	buf, err = escpos.AppendBarCode(buf, escpos.BcCODE93, "AB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf = escpos.AppendCut(buf)
+
+	want := []byte{
+		escpos.ESC, '@',
+		escpos.ESC, 'E', 1,
+		escpos.ESC, 'a', 1,
+		'H', 'i', escpos.LF,
+		escpos.GS, 'k', 72, 2, 'A', 'B',
+		escpos.GS, 'V', 0,
+	}
+	if !bytes.Equal(buf, want) {
+		t.Errorf("got % X want % X", buf, want)
+	}
+}
+
+func TestAppendInvalid(t *testing.T) {
+	prefix := []byte("receipt so far")
+
+	tests := []struct {
+		name   string
+		append func(buf []byte) ([]byte, error)
+	}{
+		{"Beep", func(buf []byte) ([]byte, error) { return escpos.AppendBeep(buf, 10, 1) }},
+		{"CutFeed", func(buf []byte) ([]byte, error) { return escpos.AppendCutFeed(buf, 256) }},
+		{"SetLineSpacing", func(buf []byte) ([]byte, error) { return escpos.AppendSetLineSpacing(buf, -1) }},
+		{"SetHT", func(buf []byte) ([]byte, error) { return escpos.AppendSetHT(buf, 8, 0) }},
+		{"SetFont", func(buf []byte) ([]byte, error) { return escpos.AppendSetFont(buf, 2) }},
+		{"Justify", func(buf []byte) ([]byte, error) { return escpos.AppendJustify(buf, 3) }},
+		{"SetHRIPosition", func(buf []byte) ([]byte, error) { return escpos.AppendSetHRIPosition(buf, 4) }},
+		{"SetBarCodeHeight", func(buf []byte) ([]byte, error) { return escpos.AppendSetBarCodeHeight(buf, 0) }},
+		{"BarCode", func(buf []byte) ([]byte, error) { return escpos.AppendBarCode(buf, escpos.BcJAN8, "123") }},
+		{"Image24", func(buf []byte) ([]byte, error) {
+			return escpos.AppendImage24(buf, image.NewGray(image.Rect(0, 0, 8, 8)), 2)
+		}},
+		{"StatusRequest", func(buf []byte) ([]byte, error) { return escpos.AppendStatusRequest(buf, 5) }},
+		{"PrinterIDRequest", func(buf []byte) ([]byte, error) { return escpos.AppendPrinterIDRequest(buf, 4) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf, err := tt.append(append([]byte{}, prefix...))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !bytes.Equal(buf, prefix) {
+				t.Errorf("buffer was changed to %q", buf)
+			}
+		})
+	}
+}
+
+func TestAppendImage(t *testing.T) {
+	// A 10x10 image needs one 24 dot band padded with white
+	img := image.NewGray(image.Rect(0, 0, 10, 10))
+	buf, err := escpos.AppendImage24(nil, img, escpos.DoubleDensity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := []byte{escpos.ESC, '3', 0, escpos.ESC, '*', 33, 10, 0}
+	if !bytes.HasPrefix(buf, header) {
+		t.Errorf("expected band to start with % X but got % X", header, buf[:len(header)])
+	}
+	if len(buf) != len(header)+10*3+1 || buf[len(buf)-1] != escpos.LF {
+		t.Errorf("expected 10 columns of 3 bytes and a line feed but got %d bytes", len(buf))
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	status := escpos.ParsePaperSensorStatus(0x12 | 0x0C | 0x60)
+	if !status.NearEnd || !status.RollEnd {
+		t.Errorf("expected paper near end and out but got %+v", status)
+	}
+
+	if status := escpos.ParseOfflineStatus(0x12); status != (escpos.OfflineStatus{}) {
+		t.Errorf("expected no offline flags in the fixed bits but got %+v", status)
+	}
+
+	if !escpos.IsStatusReply(0x12|0x04|0x08) || escpos.IsStatusReply('_') || escpos.IsStatusReply(0x11) {
+		t.Error("status replies were not told apart by their fixed bits")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	_, err := escpos.AppendBeep(nil, 10, 1)
+	var rangeErr *escpos.RangeError
+	if !errors.As(err, &rangeErr) || rangeErr.Field != "n" || rangeErr.Value != 10 || rangeErr.Max != 9 {
+		t.Errorf("expected a range error for n but got %#v", err)
+	}
+
+	_, err = escpos.AppendJustify(nil, 3)
+	var enumErr *escpos.EnumError
+	if !errors.As(err, &enumErr) || enumErr.Value != 3 || len(enumErr.Choices) != 3 {
+		t.Errorf("expected an enum error for 3 but got %#v", err)
+	}
+
+	err = escpos.CheckBarCode(escpos.BcCODABAR, "A12*34B")
+	var dataErr *escpos.BarcodeDataError
+	if !errors.As(err, &dataErr) || dataErr.Position != 3 || dataErr.Rune != '*' {
+		t.Errorf("expected a data error at position 3 but got %#v", err)
+	}
+
+	if !errors.Is(escpos.ParseOfflineStatus(0x12|0x04).Err(), escpos.ErrCoverOpen) {
+		t.Error("expected an open cover to be ErrCoverOpen")
+	}
+	if !errors.Is(escpos.ParseOfflineStatus(0x12|0x20).Err(), escpos.ErrPaperOut) {
+		t.Error("expected printing stopped by paper end to be ErrPaperOut")
+	}
+	if !errors.Is(escpos.ParsePaperSensorStatus(0x12|0x60).Err(), escpos.ErrPaperOut) {
+		t.Error("expected the roll end to be ErrPaperOut")
+	}
+	if escpos.ParsePaperSensorStatus(0x12|0x0C).Err() != nil {
+		t.Error("expected paper near end to still print")
+	}
+}
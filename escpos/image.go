@@ -0,0 +1,154 @@
+package escpos
+
+import (
+	"image"
+	"image/color"
+)
+
+// isBlack reports whether the dot at x, y relative to the image bounds
+// prints black.  Dots outside the image are padded white.
+func isBlack(img image.Image, x, y int) bool {
+	rect := img.Bounds()
+	if x >= rect.Dx() || y >= rect.Dy() {
+		return false
+	}
+
+	c := color.GrayModel.Convert(img.At(rect.Min.X+x, rect.Min.Y+y)).(color.Gray)
+	return c.Y < 0x80
+}
+
+// PackImage converts img into bands that are height dots tall, where height
+// is 8 or 24.  Each column of a band is height/8 bytes with the most
+// significant bit at the top.
+//
+// No black and white conversion is performed, anything darker than mid gray
+// is black.
+func PackImage(img image.Image, height int) [][]byte {
+	rect := img.Bounds()
+	var bands [][]byte
+
+	for y := 0; y < rect.Dy(); y += height {
+		band := []byte{}
+		for x := 0; x < rect.Dx(); x++ {
+			for z := 0; z < height; z += 8 {
+				col := byte(0)
+				for i := 0; i < 8; i++ {
+					col <<= 1
+					if isBlack(img, x, y+z+i) {
+						col |= 1
+					}
+				}
+				band = append(band, col)
+			}
+		}
+		bands = append(bands, band)
+	}
+
+	return bands
+}
+
+// UnpackImage draws bands created by PackImage onto a black and white image.
+// Index 0 of the palette is white and index 1 is black.
+func UnpackImage(bands [][]byte, height int) *image.Paletted {
+	width := 0
+	if len(bands) > 0 {
+		width = len(bands[0]) / (height / 8)
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, width, len(bands)*height), color.Palette{color.White, color.Black})
+	for b, band := range bands {
+		for i, col := range band {
+			x := i / (height / 8)
+			y := b*height + i%(height/8)*8
+			for bit := 0; bit < 8; bit++ {
+				if col&(0x80>>bit) != 0 {
+					img.SetColorIndex(x, y+bit, 1)
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+// appendImageBand appends a band from PackImage with the line spacing set to
+// 0 so bands print without gaps, followed by a line feed to print it
+func appendImageBand(buf []byte, mode byte, height int, band []byte) []byte {
+	width := len(band) / (height / 8)
+
+	buf, _ = AppendSetLineSpacing(buf, 0)
+	buf = append(buf, ESC, '*', mode, byte(width), byte(width>>8))
+	buf = append(buf, band...)
+	return AppendLF(buf)
+}
+
+// AppendImageBand8 appends one 8 dot tall band from PackImage
+//
+// The density selects the horizontal DPI of the image.  SingleDensity is
+// 90dpi while DoubleDensity is 180dpi.  Vertical DPI is always 60dpi for
+// 8-bit image data.
+func AppendImageBand8(buf []byte, band []byte, density Density) ([]byte, error) {
+	err := checkEnum(density, SingleDensity, DoubleDensity)
+	if err != nil {
+		return buf, err
+	}
+	return appendImageBand(buf, byte(density), 8, band), nil
+}
+
+// AppendImageBand24 appends one 24 dot tall band from PackImage
+//
+// SingleDensity is 90dpi while DoubleDensity is 180dpi.  Vertical DPI is
+// always 180dpi for 24-bit image data.
+func AppendImageBand24(buf []byte, band []byte, density Density) ([]byte, error) {
+	err := checkEnum(density, SingleDensity, DoubleDensity)
+	if err != nil {
+		return buf, err
+	}
+	return appendImageBand(buf, byte(density+32), 24, band), nil
+}
+
+// AppendImage8 appends every band of img in the 8-bit row format
+//
+// A live printer may drop bands sent faster than it prints them, which the
+// hoin package avoids by pacing each band.
+func AppendImage8(buf []byte, img image.Image, density Density) ([]byte, error) {
+	err := checkEnum(density, SingleDensity, DoubleDensity)
+	if err != nil {
+		return buf, err
+	}
+
+	for _, band := range PackImage(img, 8) {
+		buf, _ = AppendImageBand8(buf, band, density)
+	}
+	return buf, nil
+}
+
+// AppendImage24 appends every band of img in the 24-bit row format
+//
+// A live printer may drop bands sent faster than it prints them, which the
+// hoin package avoids by pacing each band.
+func AppendImage24(buf []byte, img image.Image, density Density) ([]byte, error) {
+	err := checkEnum(density, SingleDensity, DoubleDensity)
+	if err != nil {
+		return buf, err
+	}
+
+	for _, band := range PackImage(img, 24) {
+		buf, _ = AppendImageBand24(buf, band, density)
+	}
+	return buf, nil
+}
+
+// PreviewImage8 returns the bitmap AppendImage8 would send for img,
+// including the white padding of the last band.  Index 0 of the palette is
+// white and index 1 is black.
+func PreviewImage8(img image.Image) *image.Paletted {
+	return UnpackImage(PackImage(img, 8), 8)
+}
+
+// PreviewImage24 returns the bitmap AppendImage24 would send for img,
+// including the white padding of the last band.  Index 0 of the palette is
+// white and index 1 is black.
+func PreviewImage24(img image.Image) *image.Paletted {
+	return UnpackImage(PackImage(img, 24), 24)
+}
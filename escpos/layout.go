@@ -0,0 +1,131 @@
+package escpos
+
+const (
+	// PaperWidth is the printable width in dots of an 80mm paper roll
+	PaperWidth = 576
+	// DefaultLineSpacing is the line spacing of 1/6 inch after ESC @ and ESC 2
+	DefaultLineSpacing = 30
+	// DefaultBarCodeHeight is the bar code height in dots after ESC @
+	DefaultBarCodeHeight = 162
+)
+
+// Cell is the size in dots of a character cell
+type Cell struct {
+	Width, Height int
+}
+
+// Fonts are the character cells of Font A and Font B
+var Fonts = [2]Cell{
+	{12, 24}, // Font A
+	{9, 17},  // Font B
+}
+
+// Layout follows where things land on the paper as commands are printed,
+// without drawing anything.  It holds the settings that change the size of
+// what is printed and the line being built up until it is printed.
+//
+// The emulator draws with it and Printer.DryRun measures with it, so both
+// agree on how much paper a job uses.
+type Layout struct {
+	Font          int
+	Rotate        bool
+	LineSpacing   int
+	BarCodeHeight int
+	HRIPosition   HRIPosition
+	// Tabs are the tab stops in characters
+	Tabs []int
+
+	// X is the width of the line so far and Height the tallest thing on it
+	X, Height int
+	// Fed is the total paper fed in dots
+	Fed int
+}
+
+// NewLayout starts a layout with the settings after ESC @
+func NewLayout() *Layout {
+	l := &Layout{}
+	l.Reset()
+	return l
+}
+
+// Reset restores the settings changed by commands to what ESC @ sets.  The
+// line and the paper fed are kept.
+func (l *Layout) Reset() {
+	l.Font = 0
+	l.Rotate = false
+	l.LineSpacing = DefaultLineSpacing
+	l.BarCodeHeight = DefaultBarCodeHeight
+	l.HRIPosition = HRINone
+	l.Tabs = nil
+}
+
+// Cell returns the character cell of the current font, turned on its side
+// when rotated
+func (l *Layout) Cell() Cell {
+	c := Fonts[l.Font]
+	if l.Rotate {
+		c.Width, c.Height = c.Height, c.Width
+	}
+	return c
+}
+
+// Fits reports whether something width dots wide fits on the rest of the
+// line
+func (l *Layout) Fits(width int) bool {
+	return l.X+width <= PaperWidth
+}
+
+// Character makes room for a character cell, printing the line first when
+// the character doesn't fit on it.  It returns where the character goes on
+// the line.
+func (l *Layout) Character() int {
+	c := l.Cell()
+	if !l.Fits(c.Width) {
+		l.Flush(l.LineSpacing)
+	}
+	return l.Add(c.Width, c.Height)
+}
+
+// Add puts something width by height dots on the line and returns where it
+// goes
+func (l *Layout) Add(width, height int) int {
+	x := l.X
+	l.X += width
+	if height > l.Height {
+		l.Height = height
+	}
+	return x
+}
+
+// Move moves along the line by dx dots, stopping at the start of the line
+func (l *Layout) Move(dx int) {
+	l.X += dx
+	if l.X < 0 {
+		l.X = 0
+	}
+}
+
+// Tab moves to the next tab stop after the current position.  Nothing
+// happens when there isn't one.
+func (l *Layout) Tab() {
+	width := Fonts[l.Font].Width
+	for _, t := range l.Tabs {
+		if t*width > l.X {
+			l.X = t * width
+			return
+		}
+	}
+}
+
+// Flush prints the line and feeds the paper by at least feed dots or the
+// height of the line, whichever is larger.  It returns the height of the
+// line and the dots fed.
+func (l *Layout) Flush(feed int) (height, fed int) {
+	height = l.Height
+	if feed < height {
+		feed = height
+	}
+	l.Fed += feed
+	l.X, l.Height = 0, 0
+	return height, feed
+}
@@ -0,0 +1,114 @@
+package escpos
+
+// AppendStatusRequest appends DLE EOT n, a real-time request for status n
+// which the printer answers with one byte
+func AppendStatusRequest(buf []byte, n int) ([]byte, error) {
+	err := checkRange(n, 1, 4, "n status type")
+	if err != nil {
+		return buf, err
+	}
+	return append(buf, DLE, EOT, byte(n)), nil
+}
+
+// IsStatusReply reports whether b has the fixed bits every status reply has
+func IsStatusReply(b byte) bool {
+	return b&0b1001_0011 == 0b0001_0010
+}
+
+// PrinterIDType is the ID requested with GS I
+type PrinterIDType int
+
+const (
+	IDModel        PrinterIDType = 1
+	IDType         PrinterIDType = 2
+	IDROMVersion   PrinterIDType = 3
+	IDFirmware     PrinterIDType = 65
+	IDManufacturer PrinterIDType = 66
+	IDModelName    PrinterIDType = 67
+	IDSerialNumber PrinterIDType = 68
+)
+
+// Text reports whether the printer answers with text instead of one byte.
+// Text replies start with 0x5F and end with NUL.
+func (n PrinterIDType) Text() bool {
+	return n >= IDFirmware
+}
+
+// AppendPrinterIDRequest appends GS I n, a request for ID n
+func AppendPrinterIDRequest(buf []byte, n PrinterIDType) ([]byte, error) {
+	err := checkEnum(n, IDModel, IDType, IDROMVersion, IDFirmware, IDManufacturer, IDModelName, IDSerialNumber)
+	if err != nil {
+		return buf, err
+	}
+	return append(buf, GS, 'I', byte(n)), nil
+}
+
+type PrinterStatus struct {
+	DrawerOpen bool
+}
+
+// ParsePrinterStatus decodes the reply to a printer status request
+func ParsePrinterStatus(b byte) PrinterStatus {
+	return PrinterStatus{
+		DrawerOpen: b&0b0100 == 0b0100,
+	}
+}
+
+type OfflineStatus struct {
+	CoverOpen, FeedButton, PrintingStopped, ErrorOccured bool
+}
+
+// ParseOfflineStatus decodes the reply to an offline status request
+func ParseOfflineStatus(b byte) OfflineStatus {
+	return OfflineStatus{
+		CoverOpen:       b&0b0000_0100 == 0b0000_0100,
+		FeedButton:      b&0b0000_1000 == 0b0000_1000,
+		PrintingStopped: b&0b0010_0000 == 0b0010_0000,
+		ErrorOccured:    b&0b0100_0000 == 0b0100_0000,
+	}
+}
+
+type ErrorStatus struct {
+	AutoCutter, UnRecoverable, AutoRecoverable bool
+}
+
+// ParseErrorStatus decodes the reply to an error status request
+func ParseErrorStatus(b byte) ErrorStatus {
+	return ErrorStatus{
+		AutoCutter:      b&0b0000_1000 == 0b0000_1000,
+		UnRecoverable:   b&0b0010_0000 == 0b0010_0000,
+		AutoRecoverable: b&0b0100_0000 == 0b0100_0000,
+	}
+}
+
+// Err returns ErrCoverOpen when the cover is open and ErrPaperOut when
+// printing stopped because the paper ran out
+func (s OfflineStatus) Err() error {
+	if s.CoverOpen {
+		return ErrCoverOpen
+	}
+	if s.PrintingStopped {
+		return ErrPaperOut
+	}
+	return nil
+}
+
+type PaperSensorStatus struct {
+	NearEnd, RollEnd bool
+}
+
+// ParsePaperSensorStatus decodes the reply to a paper sensor status request
+func ParsePaperSensorStatus(b byte) PaperSensorStatus {
+	return PaperSensorStatus{
+		NearEnd: b&0b0000_1100 == 0b0000_1100,
+		RollEnd: b&0b0110_0000 == 0b0110_0000,
+	}
+}
+
+// Err returns ErrPaperOut when the paper roll has run out
+func (s PaperSensorStatus) Err() error {
+	if s.RollEnd {
+		return ErrPaperOut
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package hoin
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// ThermalDotGain is the dot gain at 50% coverage typical of thermal print
+// heads, where heat spreading from each dot darkens the paper around it
+const ThermalDotGain = 0.25
+
+// Filter adjusts a grayscale image before it is thresholded for printing
+//
+// Filters may modify img in place and return it.
+type Filter func(img *image.Gray) *image.Gray
+
+// Pipeline is a list of filters applied in order
+type Pipeline []Filter
+
+// Apply converts img to grayscale and runs every filter over it
+//
+// The original image is not modified and the result always starts at the
+// origin.
+func (p Pipeline) Apply(img image.Image) *image.Gray {
+	gray := image.NewGray(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(gray, gray.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	for _, f := range p {
+		gray = f(gray)
+	}
+	return gray
+}
+
+// pixels calls f with the offset in img.Pix of every pixel inside the bounds
+// of img, which may be a sub-image sharing Pix with a larger one
+func pixels(img *image.Gray, f func(i int)) {
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		start := img.PixOffset(bounds.Min.X, y)
+		for i := start; i < start+bounds.Dx(); i++ {
+			f(i)
+		}
+	}
+}
+
+func clampUnit(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// curve builds a filter that maps every pixel through f where 0 is black and
+// 1 is white
+func curve(f func(v float64) float64) Filter {
+	var table [256]uint8
+	for i := range table {
+		table[i] = uint8(math.Round(clampUnit(f(float64(i)/255)) * 255))
+	}
+
+	return func(img *image.Gray) *image.Gray {
+		pixels(img, func(i int) {
+			img.Pix[i] = table[img.Pix[i]]
+		})
+		return img
+	}
+}
+
+// Brightness shifts every pixel by amount, from -1 (black) to 1 (white)
+func Brightness(amount float64) Filter {
+	return curve(func(v float64) float64 {
+		return v + amount
+	})
+}
+
+// Contrast scales the distance of every pixel from middle gray by factor
+//
+// A factor of 1 leaves the image unchanged, less than 1 reduces contrast and
+// greater than 1 increases it.
+func Contrast(factor float64) Filter {
+	return curve(func(v float64) float64 {
+		return (v-0.5)*factor + 0.5
+	})
+}
+
+// Gamma applies gamma correction.  Values greater than 1 lighten the
+// mid-tones and values less than 1 darken them.
+func Gamma(gamma float64) Filter {
+	return curve(func(v float64) float64 {
+		return math.Pow(v, 1/gamma)
+	})
+}
+
+// Invert swaps black and white
+func Invert() Filter {
+	return curve(func(v float64) float64 {
+		return 1 - v
+	})
+}
+
+// DotGain compensates for dots printing larger than intended
+//
+// gain is the darkening at 50% coverage, so a gain of 0.2 prints middle gray
+// as 70% black.  The inverse of that curve is applied so mid-tones print at
+// their intended darkness.  ThermalDotGain is a good starting point.
+func DotGain(gain float64) Filter {
+	if gain <= 0 {
+		return curve(func(v float64) float64 { return v })
+	}
+
+	return curve(func(v float64) float64 {
+		// Printed coverage is c + 4*gain*c*(1-c), solve for c
+		coverage := 1 - v
+		b := 1 + 4*gain
+		c := (b - math.Sqrt(b*b-16*gain*coverage)) / (8 * gain)
+		return 1 - c
+	})
+}
+
+// AutoLevels stretches the image so the darkest pixel becomes black and the
+// lightest pixel becomes white
+func AutoLevels() Filter {
+	return func(img *image.Gray) *image.Gray {
+		low, high := uint8(255), uint8(0)
+		pixels(img, func(i int) {
+			if img.Pix[i] < low {
+				low = img.Pix[i]
+			}
+			if img.Pix[i] > high {
+				high = img.Pix[i]
+			}
+		})
+
+		if low >= high {
+			return img
+		}
+
+		scale := 255 / float64(high-low)
+		pixels(img, func(i int) {
+			img.Pix[i] = uint8(math.Round(float64(img.Pix[i]-low) * scale))
+		})
+		return img
+	}
+}
+
+// boxBlur blurs img with a box of the given radius in both directions
+func boxBlur(img *image.Gray, radius int) *image.Gray {
+	bounds := img.Bounds()
+
+	pass := func(src *image.Gray, dx, dy int) *image.Gray {
+		dst := image.NewGray(bounds)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				sum, count := 0, 0
+				for k := -radius; k <= radius; k++ {
+					p := image.Pt(x+k*dx, y+k*dy)
+					if !p.In(bounds) {
+						continue
+					}
+					sum += int(src.Pix[src.PixOffset(p.X, p.Y)])
+					count++
+				}
+				dst.Pix[dst.PixOffset(x, y)] = uint8(sum / count)
+			}
+		}
+		return dst
+	}
+
+	return pass(pass(img, 1, 0), 0, 1)
+}
+
+// UnsharpMask sharpens edges by adding amount times the difference between
+// the image and a blurred copy of radius dots
+func UnsharpMask(radius int, amount float64) Filter {
+	return func(img *image.Gray) *image.Gray {
+		if radius < 1 || amount == 0 {
+			return img
+		}
+
+		blurred := boxBlur(img, radius)
+		bounds := img.Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				i := img.PixOffset(x, y)
+				v, b := float64(img.Pix[i]), float64(blurred.Pix[blurred.PixOffset(x, y)])
+				sharp := v + amount*(v-b)
+				img.Pix[i] = uint8(math.Round(math.Max(0, math.Min(255, sharp))))
+			}
+		}
+		return img
+	}
+}
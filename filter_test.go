@@ -0,0 +1,135 @@
+package hoin_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/joeyak/hoin-printer"
+)
+
+// ramp is a 4x1 image going from black to white
+func ramp() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, 4, 1))
+	copy(img.Pix, []uint8{0, 85, 170, 255})
+	return img
+}
+
+func TestFilters(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter hoin.Filter
+		want   []uint8
+	}{
+		{"Brightness", hoin.Brightness(0.2), []uint8{51, 136, 221, 255}},
+		{"BrightnessDarker", hoin.Brightness(-0.2), []uint8{0, 34, 119, 204}},
+		{"Contrast", hoin.Contrast(2), []uint8{0, 42, 212, 255}},
+		{"ContrastNone", hoin.Contrast(1), []uint8{0, 85, 170, 255}},
+		{"Gamma", hoin.Gamma(2), []uint8{0, 147, 208, 255}},
+		{"Invert", hoin.Invert(), []uint8{255, 170, 85, 0}},
+		{"DotGain", hoin.DotGain(0.25), []uint8{0, 147, 208, 255}},
+		{"DotGainNone", hoin.DotGain(0), []uint8{0, 85, 170, 255}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.filter(ramp()).Pix
+			if string(got) != string(tt.want) {
+				t.Errorf("got %v want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutoLevels(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 3, 1))
+	copy(img.Pix, []uint8{50, 100, 150})
+
+	got := hoin.AutoLevels()(img).Pix
+	if want := []uint8{0, 127, 255}; string(got) != string(want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	flat := image.NewGray(image.Rect(0, 0, 2, 1))
+	copy(flat.Pix, []uint8{90, 90})
+	if got := hoin.AutoLevels()(flat).Pix; got[0] != 90 || got[1] != 90 {
+		t.Errorf("expected a flat image to be left alone but got %v", got)
+	}
+}
+
+func TestUnsharpMask(t *testing.T) {
+	// A vertical edge between gray and white
+	img := image.NewGray(image.Rect(0, 0, 6, 3))
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 6; x++ {
+			v := uint8(100)
+			if x >= 3 {
+				v = 200
+			}
+			img.SetGray(x, y, color.Gray{v})
+		}
+	}
+
+	got := hoin.UnsharpMask(1, 1)(img)
+	if got.GrayAt(2, 1).Y >= 100 || got.GrayAt(3, 1).Y <= 200 {
+		t.Errorf("expected the edge to be sharpened but got %v", got.Pix)
+	}
+	if got.GrayAt(0, 1).Y != 100 || got.GrayAt(5, 1).Y != 200 {
+		t.Errorf("expected flat areas to be left alone but got %v", got.Pix)
+	}
+
+	if same := hoin.UnsharpMask(0, 1)(ramp()).Pix; string(same) != string(ramp().Pix) {
+		t.Errorf("expected a radius of 0 to do nothing but got %v", same)
+	}
+}
+
+func TestFiltersSubImage(t *testing.T) {
+	filters := map[string]hoin.Filter{
+		"Brightness":  hoin.Brightness(0.5),
+		"Invert":      hoin.Invert(),
+		"AutoLevels":  hoin.AutoLevels(),
+		"UnsharpMask": hoin.UnsharpMask(2, 1),
+	}
+
+	for name, filter := range filters {
+		t.Run(name, func(t *testing.T) {
+			full := image.NewGray(image.Rect(0, 0, 10, 10))
+			for i := range full.Pix {
+				full.Pix[i] = uint8(i * 2)
+			}
+			before := append([]uint8{}, full.Pix...)
+
+			inside := image.Rect(2, 3, 7, 8)
+			sub := full.SubImage(inside).(*image.Gray)
+			got := filter(sub)
+			if got.Bounds() != inside {
+				t.Errorf("expected bounds %v but got %v", inside, got.Bounds())
+			}
+
+			for y := 0; y < 10; y++ {
+				for x := 0; x < 10; x++ {
+					i := full.PixOffset(x, y)
+					if !image.Pt(x, y).In(inside) && full.Pix[i] != before[i] {
+						t.Fatalf("pixel (%d, %d) outside of the sub-image was changed", x, y)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	src := image.NewGray(image.Rect(5, 5, 9, 6))
+	copy(src.Pix, ramp().Pix)
+
+	got := hoin.Pipeline{hoin.Invert(), hoin.Brightness(0.2)}.Apply(src)
+	if got.Bounds() != image.Rect(0, 0, 4, 1) {
+		t.Errorf("expected the result to start at the origin but got %v", got.Bounds())
+	}
+	if want := []uint8{255, 221, 136, 51}; string(got.Pix) != string(want) {
+		t.Errorf("got %v want %v", got.Pix, want)
+	}
+	if string(src.Pix) != string(ramp().Pix) {
+		t.Error("the original image was modified")
+	}
+}
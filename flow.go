@@ -0,0 +1,251 @@
+package hoin
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	XON  = 0x11
+	XOFF = 0x13
+)
+
+// textChunkSize is the most text Print sends before waiting on the flow
+// control
+const textChunkSize = 1024
+
+// FlowControl paces bulk data, like image bands and long text, so it doesn't
+// overflow the receive buffer of the printer.  Anything sent too fast is
+// dropped by the printer along with the commands after it.
+type FlowControl interface {
+	// Wait is called after each chunk of bulk data is written with the
+	// number of bytes in the chunk.  It returns once more can be sent.
+	Wait(p Printer, n int) error
+}
+
+// StatusPolling waits for the printer to answer an error status request
+// after every chunk.  The printer only answers once everything before the
+// request has been printed.
+//
+// This is the default but it needs a transport that can be read from, which
+// many /dev/usb/lp* devices can't.
+func StatusPolling() FlowControl {
+	return statusPolling{}
+}
+
+type statusPolling struct{}
+
+func (statusPolling) Wait(p Printer, n int) error {
+	_, err := p.TransmitErrorStatus()
+	return err
+}
+
+// ReadyPolling works like StatusPolling but asks for the offline status
+// instead.  An error matching ErrCoverOpen or ErrPaperOut is returned as
+// soon as the printer stops, so the rest of the job isn't sent to a printer
+// that can't print it.
+func ReadyPolling() FlowControl {
+	return readyPolling{}
+}
+
+type readyPolling struct{}
+
+func (readyPolling) Wait(p Printer, n int) error {
+	return p.checkOffline()
+}
+
+// FixedDelay sleeps for d after every chunk
+//
+// Around 35ms per 24 dot image band works for a HOP-E802 over the network.
+func FixedDelay(d time.Duration) FlowControl {
+	return fixedDelay(d)
+}
+
+type fixedDelay time.Duration
+
+func (d fixedDelay) Wait(p Printer, n int) error {
+	return p.sleep(time.Duration(d))
+}
+
+// ByteRate limits bulk data to an average of bytesPerSecond
+func ByteRate(bytesPerSecond int) FlowControl {
+	return &byteRate{rate: bytesPerSecond}
+}
+
+type byteRate struct {
+	rate int
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func (r *byteRate) Wait(p Printer, n int) error {
+	if r.rate <= 0 {
+		return fmt.Errorf("byte rate must be more than 0 but was %d", r.rate)
+	}
+
+	r.mu.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	r.next = r.next.Add(time.Duration(n) * time.Second / time.Duration(r.rate))
+	wait := r.next.Sub(now)
+	r.mu.Unlock()
+
+	return p.sleep(wait)
+}
+
+// NoFlowControl sends everything as fast as the transport accepts it
+func NoFlowControl() FlowControl {
+	return noFlowControl{}
+}
+
+type noFlowControl struct{}
+
+func (noFlowControl) Wait(p Printer, n int) error {
+	return nil
+}
+
+// readDeadliner is implemented by transports like net.Conn and *os.File
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+const (
+	// xonXoffPoll is how long to wait for an XOFF after each chunk
+	xonXoffPoll = time.Millisecond
+	// xonXoffFallback is how long to wait for an XOFF on transports without
+	// read deadlines, which can't be checked for one any sooner
+	xonXoffFallback = 20 * time.Millisecond
+)
+
+// XonXoff pauses when the printer sends XOFF until it sends XON, which
+// serial printers do when their buffer is nearly full and has room again.
+// An error is returned if XON doesn't come within timeout.
+//
+// XON and XOFF are read by the same background reader as status replies, so
+// status requests can be made while waiting.  Transports with read
+// deadlines are checked for XOFF after every chunk, others are given a short
+// while to send one.
+func XonXoff(timeout time.Duration) FlowControl {
+	return xonXoff{timeout: timeout}
+}
+
+type xonXoff struct {
+	timeout time.Duration
+}
+
+func (x xonXoff) Wait(p Printer, n int) error {
+	r := p.replies
+
+	r.mu.Lock()
+	r.flowWaiters++
+	r.start(p.dst)
+	since := r.started
+	r.pollUntil = time.Now().Add(xonXoffPoll)
+	r.interrupt(r.pollUntil)
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		r.flowWaiters--
+		r.release()
+		r.mu.Unlock()
+	}()
+
+	var done <-chan struct{}
+	if p.ctx != nil {
+		done = p.ctx.Done()
+	}
+
+	pausedAt := time.Time{}
+	fallback := time.Now().Add(xonXoffFallback)
+	for {
+		r.mu.Lock()
+		// Transports that return io.EOF when there's nothing to read stop
+		// the reader, so start it again to check for more
+		r.start(p.dst)
+		paused, changed, err := r.paused, r.flowChanged, r.flowError()
+		checked, readDone := r.done > since, r.readDone
+		r.mu.Unlock()
+
+		if err != nil {
+			return fmt.Errorf("could not read flow control: %w", err)
+		}
+
+		var wait time.Duration
+		if paused {
+			wait, err = x.pausedFor(&pausedAt)
+			if err != nil {
+				return err
+			}
+			if wait > xonXoffPoll {
+				wait = xonXoffPoll
+			}
+			// Only wake up for XON or to restart the reader
+			readDone = nil
+		} else {
+			if checked {
+				return nil
+			}
+			wait = time.Until(fallback)
+			if wait <= 0 {
+				return nil
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-changed:
+		case <-readDone:
+		case <-timer.C:
+		case <-done:
+			timer.Stop()
+			return p.contextErr()
+		}
+		timer.Stop()
+	}
+}
+
+// pausedFor returns how long until the timeout for XON, starting the clock
+// at pausedAt if it isn't already
+func (x xonXoff) pausedFor(pausedAt *time.Time) (time.Duration, error) {
+	if pausedAt.IsZero() {
+		*pausedAt = time.Now()
+	}
+	wait := x.timeout - time.Since(*pausedAt)
+	if wait <= 0 {
+		return 0, timeoutError{fmt.Errorf("printer did not send XON within %s", x.timeout)}
+	}
+	return wait, nil
+}
+
+// WithFlowControl sets how bulk data is paced
+func WithFlowControl(fc FlowControl) Option {
+	return func(p *Printer) {
+		p.flow = fc
+	}
+}
+
+// wait paces bulk data after a chunk of n bytes has been sent
+func (p Printer) wait(n int) error {
+	errMsg := "could not wait for printer: %w"
+
+	err := p.Flush()
+	if err != nil {
+		return fmt.Errorf(errMsg, err)
+	}
+
+	flow := p.flow
+	if flow == nil {
+		flow = StatusPolling()
+	}
+
+	err = flow.Wait(p, n)
+	if err != nil {
+		return fmt.Errorf(errMsg, err)
+	}
+	return nil
+}
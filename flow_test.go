@@ -0,0 +1,107 @@
+package hoin_test
+
+import (
+	"errors"
+	"image"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joeyak/hoin-printer"
+	"github.com/joeyak/hoin-printer/emulator"
+)
+
+func TestFlowControl(t *testing.T) {
+	// Each band of a full width 24 dot image is 1737 bytes and takes 30ms to
+	// print at 100mm/s, so without flow control the buffer overflows
+	throttle := emulator.ThrottleOptions{
+		BufferSize: 4096,
+		Speed:      100,
+		XonXoff:    true,
+	}
+	img := image.NewGray(image.Rect(0, 0, emulator.PaperWidth, 24*6))
+
+	tests := []struct {
+		name    string
+		flow    hoin.FlowControl
+		dropped bool
+	}{
+		{"Default", nil, false},
+		{"StatusPolling", hoin.StatusPolling(), false},
+		{"FixedDelay", hoin.FixedDelay(40 * time.Millisecond), false},
+		{"ByteRate", hoin.ByteRate(40_000), false},
+		{"XonXoff", hoin.XonXoff(time.Second), false},
+		{"None", hoin.NoFlowControl(), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttled := emulator.NewThrottled(emulator.New(), throttle)
+
+			var opts []hoin.Option
+			if tt.flow != nil {
+				opts = append(opts, hoin.WithFlowControl(tt.flow))
+			}
+
+			err := hoin.NewPrinter(throttled, opts...).PrintImage24(img, hoin.DoubleDensity)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stats := throttled.Stats()
+			if tt.dropped != (stats.Dropped > 0) {
+				t.Errorf("expected dropped bytes to be %t but %d were dropped", tt.dropped, stats.Dropped)
+			}
+		})
+	}
+}
+
+func TestXonXoffTimeout(t *testing.T) {
+	// Nothing is printed at 0.01mm/s so XON never comes
+	throttled := emulator.NewThrottled(emulator.New(), emulator.ThrottleOptions{
+		BufferSize: 64,
+		Speed:      0.01,
+		XonXoff:    true,
+	})
+
+	printer := hoin.NewPrinter(throttled, hoin.WithFlowControl(hoin.XonXoff(50*time.Millisecond)))
+	err := printer.Print(strings.Repeat("\n", 2048))
+	if !errors.Is(err, hoin.ErrTimeout) {
+		t.Fatalf("expected %q to be %q waiting for XON", err, hoin.ErrTimeout)
+	}
+}
+
+// blockingPrinter takes everything written to it and blocks reads forever,
+// without read deadlines to interrupt them
+type blockingPrinter struct {
+	block chan struct{}
+}
+
+func (b blockingPrinter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (b blockingPrinter) Read(p []byte) (int, error) {
+	<-b.block
+	return 0, io.EOF
+}
+
+func TestXonXoffWithoutDeadlines(t *testing.T) {
+	transport := blockingPrinter{make(chan struct{})}
+	defer close(transport.block)
+
+	printer := hoin.NewPrinter(transport, hoin.WithFlowControl(hoin.XonXoff(time.Second)))
+
+	done := make(chan error, 1)
+	go func() { done <- printer.Print(strings.Repeat("\n", 2048)) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiting for XOFF blocked on the read")
+	}
+}
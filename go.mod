@@ -3,6 +3,10 @@ module github.com/joeyak/hoin-printer
 go 1.18
 
 require (
-	github.com/alexflint/go-arg v1.5.1 // indirect
-	github.com/alexflint/go-scalar v1.2.0 // indirect
+	github.com/alexflint/go-arg v1.5.1
+	golang.org/x/image v0.24.0
+	golang.org/x/sys v0.30.0
+	golang.org/x/text v0.22.0
 )
+
+require github.com/alexflint/go-scalar v1.2.0 // indirect
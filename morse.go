@@ -117,7 +117,10 @@ func printMorse(p Printer, message string, f func(t int) error) error {
 				return fmt.Errorf(errMsg, err)
 			}
 		}
-		time.Sleep(100 * time.Millisecond)
+		err = p.sleep(100 * time.Millisecond)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
 	}
 	return nil
 }
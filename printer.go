@@ -1,150 +1,223 @@
 package hoin
 
 import (
+	"context"
 	"fmt"
 	"image"
-	"image/color"
 	"io"
-	"net"
-	"strings"
+	"time"
+
+	"github.com/joeyak/hoin-printer/escpos"
 )
 
 const (
 	// Default ip and port for hoin printers
 	DefaultPrinterIP = "192.168.1.23:9100"
 
-	HT  = 0x09
-	LF  = 0x0A
-	CR  = 0x0D
-	GS  = 0x1D
-	ESC = 0x1B
-	DLE = 0x10
-)
+	// Printable width in dots of an 80mm paper roll
+	DefaultPaperWidth = escpos.PaperWidth
 
-type Font int
+	HT  = escpos.HT
+	LF  = escpos.LF
+	CR  = escpos.CR
+	GS  = escpos.GS
+	ESC = escpos.ESC
+	DLE = escpos.DLE
+)
 
-const (
-	FontA Font = iota
-	FontB
+type (
+	Font          = escpos.Font
+	Justification = escpos.Justification
+	HRIPosition   = escpos.HRIPosition
+	Density       = escpos.Density
+	BarCode       = escpos.BarCode
+
+	// Validation errors, use errors.As to check for them
+	RangeError       = escpos.RangeError
+	EnumError        = escpos.EnumError
+	BarcodeDataError = escpos.BarcodeDataError
 )
 
-type Justification int
+var (
+	ErrPaperOut  = escpos.ErrPaperOut
+	ErrCoverOpen = escpos.ErrCoverOpen
+)
 
 const (
-	LeftJustify Justification = iota
-	CenterJustify
-	RightJustify
+	FontA = escpos.FontA
+	FontB = escpos.FontB
 )
 
-type HRIPosition int
-
 const (
-	HRINone HRIPosition = iota
-	HRIAbove
-	HRIBelow
-	HRIBoth
+	LeftJustify   = escpos.LeftJustify
+	CenterJustify = escpos.CenterJustify
+	RightJustify  = escpos.RightJustify
 )
 
-// Density represents the DPI to use when printing images.
-type Density int
+const (
+	HRINone  = escpos.HRINone
+	HRIAbove = escpos.HRIAbove
+	HRIBelow = escpos.HRIBelow
+	HRIBoth  = escpos.HRIBoth
+)
 
 const (
 	// SingleDensity is 90dpi
-	SingleDensity Density = iota
+	SingleDensity = escpos.SingleDensity
 	// DoubleDensity is 180dpi
-	DoubleDensity
+	DoubleDensity = escpos.DoubleDensity
 )
 
-type BarCode int
-
 const (
-	BcUPCA BarCode = iota
-	BcUPCE
-	BcJAN13
-	BcJAN8
-	BcCODE39
-	BcITF
-	BcCODABAR
-	BcCODE93  BarCode = 72
-	BcCODE123 BarCode = 73
+	BcUPCA    = escpos.BcUPCA
+	BcUPCE    = escpos.BcUPCE
+	BcJAN13   = escpos.BcJAN13
+	BcJAN8    = escpos.BcJAN8
+	BcCODE39  = escpos.BcCODE39
+	BcITF     = escpos.BcITF
+	BcCODABAR = escpos.BcCODABAR
+	BcCODE93  = escpos.BcCODE93
+	BcCODE128 = escpos.BcCODE128
+
+	// Deprecated: BcCODE123 is a misspelling of BcCODE128
+	BcCODE123 = escpos.BcCODE128
 )
 
-var (
-	lengthBarcodes = []BarCode{BcCODE93, BcCODE123}
-	allBarcodes    = append(lengthBarcodes, BcUPCA, BcUPCE, BcJAN13, BcJAN8, BcCODE39, BcITF, BcCODABAR)
-)
-
-func inSlice[T ~int](v T, s ...T) bool {
-	for _, a := range s {
-		if v == a {
-			return true
+func checkEnum[T ~int](e T, enums ...T) error {
+	for _, a := range enums {
+		if e == a {
+			return nil
 		}
 	}
-	return false
-}
 
-func checkEnum[T ~int](e T, enums ...T) error {
-	if inSlice(e, enums...) {
-		return nil
+	choices := make([]int, len(enums))
+	for i, a := range enums {
+		choices[i] = int(a)
 	}
-	return fmt.Errorf("%v was not a valid choice from %v", e, enums)
+	return &EnumError{Value: int(e), Choices: choices}
 }
 
 func checkRange(n, min, max int, info string) error {
 	if n < min || max < n {
-		return fmt.Errorf("%s must be between %d and %d", info, min, max)
+		return &RangeError{Field: info, Min: min, Max: max, Value: n}
 	}
 	return nil
 }
 
-func boolToByte(b bool) byte {
-	if b {
-		return 1
-	}
-	return 0
-}
-
 type Printer struct {
-	dst io.ReadWriter
+	dst  io.ReadWriter
+	flow FlowControl
+	buf  *buffer
+	ctx  context.Context
+
+	replies *replies
+
+	// reconnect is only used while dialling
+	reconnect *ReconnectOptions
 }
 
-func NewPrinter(dst io.ReadWriter) Printer {
-	return Printer{
-		dst: dst,
+// Option changes how a Printer talks to the printer
+type Option func(*Printer)
+
+func NewPrinter(dst io.ReadWriter, opts ...Option) Printer {
+	p := Printer{
+		dst:     dst,
+		replies: newReplies(),
+	}
+	for _, opt := range opts {
+		opt(&p)
 	}
+	return p
 }
 
-func NewIpPrinter(addr string) (Printer, error) {
-	conn, err := net.Dial("tcp", addr)
-	if err != nil {
-		return Printer{}, fmt.Errorf("unable to dial: %w", err)
-	}
-	return NewPrinter(conn), nil
+// NewIpPrinter connects to the printer at addr, giving up after
+// DefaultDialTimeout
+func NewIpPrinter(addr string, opts ...Option) (Printer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDialTimeout)
+	defer cancel()
+	return NewIpPrinterContext(ctx, addr, opts...)
 }
 
+// Close flushes any buffered commands and closes the printer
 func (p Printer) Close() error {
+	flushErr := p.Flush()
+
 	closer, ok := p.dst.(io.Closer)
 	if p.dst == nil || !ok {
-		return nil
+		return flushErr
 	}
 
 	err := closer.Close()
+	if err == nil {
+		err = flushErr
+	}
 	if err != nil {
 		return fmt.Errorf("could not close printer: %w", err)
 	}
 	return nil
 }
 
+// writeDst writes straight to the transport within the deadline of the
+// context
+func (p Printer) writeDst(b []byte) (int, error) {
+	var setDeadline func(time.Time) error
+	if deadliner, ok := p.dst.(writeDeadliner); ok {
+		setDeadline = deadliner.SetWriteDeadline
+	}
+	return p.withDeadline(setDeadline, func() (int, error) { return p.dst.Write(b) })
+}
+
 func (p Printer) Write(b []byte) (int, error) {
-	n, err := p.dst.Write(b)
+	if p.buf != nil {
+		n, err := p.buf.write(p.writeDst, b)
+		if err != nil {
+			return n, fmt.Errorf("could not write to printer: %w", err)
+		}
+		return n, nil
+	}
+
+	n, err := p.writeDst(b)
 	if err != nil {
 		return n, fmt.Errorf("could not write to printer: %w", err)
 	}
 	return n, nil
 }
 
+// send writes a command encoded by escpos, returning the error from encoding
+// it if there was one
+func (p Printer) send(data []byte, err error) error {
+	if err != nil {
+		return err
+	}
+
+	_, err = p.Write(data)
+	return err
+}
+
+// Read flushes any buffered commands and then reads from the printer
+//
+// It reads the transport directly once the background reader for replies
+// has stopped, and fails while a Subscribe channel or a status request is
+// still waiting on it.  When a request timed out on a transport without read
+// deadlines the reader can't be stopped until the printer sends something,
+// so Read fails with ErrReadPending instead of blocking.
 func (p Printer) Read(b []byte) (int, error) {
-	n, err := p.dst.Read(b)
+	err := p.Flush()
+	if err != nil {
+		return 0, fmt.Errorf("could not read from printer: %w", err)
+	}
+
+	err = p.idle()
+	if err != nil {
+		return 0, fmt.Errorf("could not read from printer: %w", err)
+	}
+
+	var setDeadline func(time.Time) error
+	if deadliner, ok := p.dst.(readDeadliner); ok {
+		setDeadline = deadliner.SetReadDeadline
+	}
+
+	n, err := p.withDeadline(setDeadline, func() (int, error) { return p.dst.Read(b) })
 	if err != nil {
 		return n, fmt.Errorf("could not read from printer: %w", err)
 	}
@@ -152,7 +225,7 @@ func (p Printer) Read(b []byte) (int, error) {
 }
 
 func (p Printer) Initialize() error {
-	_, err := p.Write([]byte{ESC, '@'})
+	err := p.send(escpos.AppendInitialize(nil), nil)
 	if err != nil {
 		return fmt.Errorf("could not initialize printer: %w", err)
 	}
@@ -164,30 +237,36 @@ func (p Printer) Initialize() error {
 // Duration is dependent on the model. For the HOP-E802
 // each duration is around 100ms
 func (p Printer) Beep(n, t int) error {
-	errMsg := "could not beep the printer: %w"
-
-	err := checkRange(n, 1, 9, "n")
-	if err != nil {
-		return fmt.Errorf(errMsg, err)
-	}
-
-	err = checkRange(t, 1, 9, "t")
-	if err != nil {
-		return fmt.Errorf(errMsg, err)
-	}
-
-	_, err = p.Write([]byte{ESC, 'B', byte(n), byte(t)})
+	err := p.send(escpos.AppendBeep(nil, n, t))
 	if err != nil {
-		return fmt.Errorf(errMsg, err)
+		return fmt.Errorf("could not beep the printer: %w", err)
 	}
-
 	return nil
 }
 
+// Print sends the text to the printer
+//
+// Text longer than 1024 bytes is sent in chunks paced by the flow control.
 func (p Printer) Print(a ...any) error {
-	_, err := p.Write([]byte(fmt.Sprint(a...)))
+	errMsg := "could not print %q: %w"
+
+	data := []byte(fmt.Sprint(a...))
+	for len(data) > textChunkSize {
+		_, err := p.Write(data[:textChunkSize])
+		if err != nil {
+			return fmt.Errorf(errMsg, a, err)
+		}
+		data = data[textChunkSize:]
+
+		err = p.wait(textChunkSize)
+		if err != nil {
+			return fmt.Errorf(errMsg, a, err)
+		}
+	}
+
+	_, err := p.Write(data)
 	if err != nil {
-		return fmt.Errorf("could not print %q: %w", a, err)
+		return fmt.Errorf(errMsg, a, err)
 	}
 	return nil
 }
@@ -204,7 +283,7 @@ func (p Printer) Printf(format string, a ...any) error {
 //
 // By default HT will do nothing if SetHT is not called with tab positions
 func (p Printer) HT() error {
-	_, err := p.Write([]byte{HT})
+	err := p.send(escpos.AppendHT(nil), nil)
 	if err != nil {
 		return fmt.Errorf("could not send HT: %w", err)
 	}
@@ -213,7 +292,7 @@ func (p Printer) HT() error {
 
 // LF prints the data in the print buffer and feeds one line
 func (p Printer) LF() error {
-	_, err := p.Write([]byte{LF})
+	err := p.send(escpos.AppendLF(nil), nil)
 	if err != nil {
 		return fmt.Errorf("could not send LF: %w", err)
 	}
@@ -222,7 +301,7 @@ func (p Printer) LF() error {
 
 // CR prints and does a carriage return
 func (p Printer) CR() error {
-	_, err := p.Write([]byte{CR})
+	err := p.send(escpos.AppendCR(nil), nil)
 	if err != nil {
 		return fmt.Errorf("could not send CR: %w", err)
 	}
@@ -231,7 +310,7 @@ func (p Printer) CR() error {
 
 // Cut cuts the paper
 func (p Printer) Cut() error {
-	_, err := p.Write([]byte{GS, 'V', 0})
+	err := p.send(escpos.AppendCut(nil), nil)
 	if err != nil {
 		return fmt.Errorf("could not cut paper: %w", err)
 	}
@@ -240,16 +319,9 @@ func (p Printer) Cut() error {
 
 // CutFeed feeds the paper n units and then cuts it
 func (p Printer) CutFeed(n int) error {
-	errMsg := "could not feed and cut the paper: %w"
-
-	err := checkRange(n, 0, 255, "n")
-	if err != nil {
-		return fmt.Errorf(errMsg, err)
-	}
-
-	_, err = p.Write([]byte{GS, 'V', 66, byte(n)})
+	err := p.send(escpos.AppendCutFeed(nil, n))
 	if err != nil {
-		return fmt.Errorf(errMsg, err)
+		return fmt.Errorf("could not feed and cut the paper: %w", err)
 	}
 	return nil
 }
@@ -257,7 +329,7 @@ func (p Printer) CutFeed(n int) error {
 // ResetLineSpacing sets the spacing to the default which
 // is 1/6-inch lines (approx. 4.23mm)
 func (p Printer) ResetLineSpacing() error {
-	_, err := p.Write([]byte{ESC, '2'})
+	err := p.send(escpos.AppendResetLineSpacing(nil), nil)
 	if err != nil {
 		return fmt.Errorf("could not reset line spacing: %w", err)
 	}
@@ -266,53 +338,29 @@ func (p Printer) ResetLineSpacing() error {
 
 // SetLineSpacing sets the line spacing to n * v/h motion units in inches
 func (p Printer) SetLineSpacing(n int) error {
-	errMsg := "could not set line spacing: %w"
-
-	err := checkRange(n, 0, 255, "n")
+	err := p.send(escpos.AppendSetLineSpacing(nil, n))
 	if err != nil {
-		return fmt.Errorf(errMsg, err)
+		return fmt.Errorf("could not set line spacing: %w", err)
 	}
-
-	_, err = p.Write([]byte{ESC, '3', byte(n)})
-	if err != nil {
-		return fmt.Errorf(errMsg, err)
-	}
-
-	return err
+	return nil
 }
 
 // Feed feeds the paper n units
 func (p Printer) Feed(n int) error {
-	errMsg := "could not feed paper: %w"
-
-	err := checkRange(n, 0, 255, "n")
-	if err != nil {
-		return fmt.Errorf(errMsg, err)
-	}
-
-	_, err = p.Write([]byte{ESC, 'J', byte(n)})
+	err := p.send(escpos.AppendFeed(nil, n))
 	if err != nil {
-		return fmt.Errorf(errMsg, err)
+		return fmt.Errorf("could not feed paper: %w", err)
 	}
-
-	return err
+	return nil
 }
 
 // FeedLines feeds the paper n lines
 func (p Printer) FeedLines(n int) error {
-	errMsg := "could not feed lines: %w"
-
-	err := checkRange(n, 0, 255, "n")
-	if err != nil {
-		return fmt.Errorf(errMsg, err)
-	}
-
-	_, err = p.Write([]byte{ESC, 'd', byte(n)})
+	err := p.send(escpos.AppendFeedLines(nil, n))
 	if err != nil {
-		return fmt.Errorf(errMsg, err)
+		return fmt.Errorf("could not feed lines: %w", err)
 	}
-
-	return err
+	return nil
 }
 
 // SetHT sets the horizontal tab positions
@@ -322,46 +370,26 @@ func (p Printer) FeedLines(n int) error {
 // A max of 32 positions can be set
 // Calling SetHT with no argments resets the tab positions
 func (p Printer) SetHT(positions ...int) error {
-	errMsg := "could not set horizontal tab positions: %w"
-
-	if len(positions) > 32 {
-		return fmt.Errorf("more than 32 positions was set")
-	}
-
-	var data []byte
-	for i, pos := range positions {
-		err := checkRange(pos, 1, 255, fmt.Sprintf("position %d", i))
-		if err != nil {
-			return fmt.Errorf(errMsg, err)
-		}
-
-		data = append(data, byte(pos))
-	}
-
-	data = append([]byte{ESC, 'D'}, data...)
-	data = append(data, 0)
-
-	_, err := p.Write(data)
+	err := p.send(escpos.AppendSetHT(nil, positions...))
 	if err != nil {
-		return fmt.Errorf(errMsg, err)
+		return fmt.Errorf("could not set horizontal tab positions: %w", err)
 	}
-
 	return nil
 }
 
 // SetTabs will set up to 32 tab positions at the given width intervals.  If
 // the tab value exceeds 256, fewer than 32 positions will be set.
 func (p Printer) SetTabs(width int) error {
-	tabs := []int{}
-	for i := width; i < 256 && len(tabs) < 32; i+=width {
-		tabs = append(tabs, i)
+	err := p.send(escpos.AppendSetTabs(nil, width))
+	if err != nil {
+		return fmt.Errorf("could not set horizontal tab positions: %w", err)
 	}
-	return p.SetHT(tabs...)
+	return nil
 }
 
 // SetBold turns emphasized mode on or off
 func (p Printer) SetBold(b bool) error {
-	_, err := p.Write([]byte{ESC, 'E', boolToByte(b)})
+	err := p.send(escpos.AppendSetBold(nil, b), nil)
 	if err != nil {
 		return fmt.Errorf("could not set bold to %t: %w", b, err)
 	}
@@ -372,7 +400,7 @@ func (p Printer) SetBold(b bool) error {
 //
 // When text is double-width or double-height the text will be mirrored
 func (p Printer) SetRotate90(b bool) error {
-	_, err := p.Write([]byte{ESC, 'V', boolToByte(b)})
+	err := p.send(escpos.AppendSetRotate90(nil, b), nil)
 	if err != nil {
 		return fmt.Errorf("could not set bold to %t: %w", b, err)
 	}
@@ -384,7 +412,7 @@ func (p Printer) SetRotate90(b bool) error {
 // If b is true then it will print black text on white background
 // If b is false then it will print white text on black background
 func (p Printer) SetReversePrinting(b bool) error {
-	_, err := p.Write([]byte{GS, 'B', boolToByte(b)})
+	err := p.send(escpos.AppendSetReversePrinting(nil, b), nil)
 	if err != nil {
 		return fmt.Errorf("could not set reverse printing mode: %w", err)
 	}
@@ -396,37 +424,36 @@ func (p Printer) SetReversePrinting(b bool) error {
 // n=0 selects font A
 // n=1 selects font B
 func (p Printer) SetFont(f Font) error {
-	errMsg := "could not set font to %v: %w"
-
-	err := checkEnum(f, FontA, FontB)
+	err := p.send(escpos.AppendSetFont(nil, f))
 	if err != nil {
-		return fmt.Errorf(errMsg, f, err)
+		return fmt.Errorf("could not set font to %v: %w", f, err)
 	}
-
-	_, err = p.Write([]byte{ESC, 'M', byte(f)})
-	if err != nil {
-		return fmt.Errorf(errMsg, f, err)
-	}
-
 	return nil
 }
 
 // Justify sets the alignment to n
 func (p Printer) Justify(j Justification) error {
-	errMsg := "could not set justify to %v: %w"
-
-	err := checkEnum(j, CenterJustify, LeftJustify, RightJustify)
+	err := p.send(escpos.AppendJustify(nil, j))
 	if err != nil {
-		return fmt.Errorf(errMsg, j, err)
-	}
-
-	_, err = p.Write([]byte{ESC, 'a', byte(j)})
-	if err != nil {
-		return fmt.Errorf(errMsg, j, err)
+		return fmt.Errorf("could not set justify to %v: %w", j, err)
 	}
 	return nil
 }
 
+// PreviewImage8 returns the bitmap PrintImage8 would send for img, including
+// the white padding of the last band.  Index 0 of the palette is white and
+// index 1 is black.
+func PreviewImage8(img image.Image) *image.Paletted {
+	return escpos.PreviewImage8(img)
+}
+
+// PreviewImage24 returns the bitmap PrintImage24 would send for img,
+// including the white padding of the last band.  Index 0 of the palette is
+// white and index 1 is black.
+func PreviewImage24(img image.Image) *image.Paletted {
+	return escpos.PreviewImage24(img)
+}
+
 // PrintImage8 prints an image in the 8-bit row format.  In this format each
 // row is 8 dots tall.
 //
@@ -437,51 +464,21 @@ func (p Printer) Justify(j Justification) error {
 // No black and white conversion is performed on the provided image.  The
 // image should be converted before calling this function.
 func (p Printer) PrintImage8(img image.Image, density Density) error {
-	imgRect := img.Bounds()
-	var err error
 	errMsg := "could not print 8 dot image: %w"
 
-	err = checkEnum(density, SingleDensity, DoubleDensity)
-	if err != nil {
-		return fmt.Errorf(errMsg, err)
-	}
-
 	// 8 dot density (meta row is 8 dots tall)
-	for y := 0; y < imgRect.Max.Y; y += 8 {
-		row := []byte{}
-		for x := 0; x < imgRect.Max.X; x++ {
-			col := byte(0)
-
-			for i := 0; i < 8; i++ {
-				col <<= 1
-				// Pad the bottom row to be white
-				if y+i > imgRect.Max.Y {
-					continue
-				}
-				c := color.GrayModel.Convert(img.At(x, y+i)).(color.Gray)
-				if c.Y < 0x80 {
-					col |= 1
-				}
-			}
-
-			row = append(row, col)
-		}
-
-		data := []byte{ESC, '*', byte(density), byte(len(row)), byte(len(row) >> 8)}
-
-		if err = p.SetLineSpacing(0); err != nil {
-			return fmt.Errorf(errMsg, err)
-		}
-		if _, err = p.Write(append(data, row...)); err != nil {
+	for _, band := range escpos.PackImage(img, 8) {
+		data, err := escpos.AppendImageBand8(nil, band, density)
+		if err != nil {
 			return fmt.Errorf(errMsg, err)
 		}
-		if err = p.LF(); err != nil {
+
+		if _, err = p.Write(data); err != nil {
 			return fmt.Errorf(errMsg, err)
 		}
 
 		// Wait for line to finish
-		_, err = p.TransmitErrorStatus()
-		if err != nil {
+		if err = p.wait(len(data)); err != nil {
 			return fmt.Errorf(errMsg, err)
 		}
 	}
@@ -496,64 +493,26 @@ func (p Printer) PrintImage8(img image.Image, density Density) error {
 // of the printed image.  SingleDensity is 90dpi while DoubleDensity is
 // 180dpi.  Vertical DPI is always 180dpi for 24-bit image data.
 func (p Printer) PrintImage24(img image.Image, density Density) error {
-	imgRect := img.Bounds()
-	var err error
 	errMsg := "could not print 24 dot image: %w"
 
-	err = checkEnum(density, SingleDensity, DoubleDensity)
-	if err != nil {
-		return fmt.Errorf(errMsg, err)
-	}
-
-	command := []byte{ESC, 0x2A, byte(density + 32), byte(imgRect.Max.X), byte(imgRect.Max.X >> 8)}
-
 	// 24 dot density (meta row is 24 dots tall (3 bytes))
-	for y := 0; y < imgRect.Max.Y; y += 24 {
-		row := []byte{}
-
-		for x := 0; x < imgRect.Max.X; x++ {
-			for z := 0; z < 3; z++ {
-				col := byte(0)
-				for i := 0; i < 8; i++ {
-					col <<= 1
-					// Pad the bottom row to be white
-					if (y+z*8)+i > imgRect.Max.Y {
-						continue
-					}
-
-					c := color.GrayModel.Convert(img.At(x, (y+z*8)+i)).(color.Gray)
-					if c.Y < 0x80 {
-						col |= 1
-					}
-				}
-				row = append(row, col)
-			}
-		}
-
-		err = p.SetLineSpacing(0)
-		if err != nil {
-			return fmt.Errorf(errMsg, err)
-		}
-
-		_, err = p.Write(append(command, row...))
+	for _, band := range escpos.PackImage(img, 24) {
+		data, err := escpos.AppendImageBand24(nil, band, density)
 		if err != nil {
 			return fmt.Errorf(errMsg, err)
 		}
 
-		err = p.LF()
+		_, err = p.Write(data)
 		if err != nil {
 			return fmt.Errorf(errMsg, err)
 		}
 
 		// If data is sent to fast it won't make it to the printer and will
 		// stop printing part of the way through an image.  This will also
-		// lose any commands sent after the image.  Sleeping for 35ms seems to
-		// be the best balance between not printing and reducing banding.
-		// time.Sleep(time.Millisecond * 35)
-
-		// Leaving above there just in case below is a footgun, but below should
-		// wait till print buffer is done to write more lines
-		_, err = p.TransmitErrorStatus()
+		// lose any commands sent after the image.  By default this waits
+		// till the print buffer is done to write more lines, but FixedDelay
+		// with 35ms also works without reading from the printer.
+		err = p.wait(len(data))
 		if err != nil {
 			return fmt.Errorf(errMsg, err)
 		}
@@ -565,23 +524,16 @@ func (p Printer) PrintImage24(img image.Image, density Density) error {
 // SetHRIPosition sets the printing position of the HRI characters
 // in relation to the barcode
 func (p Printer) SetHRIPosition(hp HRIPosition) error {
-	errMsg := "could not set HRI position: %w"
-
-	err := checkEnum(hp, HRINone, HRIAbove, HRIBelow, HRIBoth)
-	if err != nil {
-		return fmt.Errorf(errMsg, err)
-	}
-
-	_, err = p.Write([]byte{GS, 'H', byte(hp)})
+	err := p.send(escpos.AppendSetHRIPosition(nil, hp))
 	if err != nil {
-		return fmt.Errorf(errMsg, err)
+		return fmt.Errorf("could not set HRI position: %w", err)
 	}
 	return nil
 }
 
 // ResetBarCodeHeight sets the bar code height to 162
 func (p Printer) ResetBarCodeHeight() error {
-	err := p.SetBarCodeHeight(162)
+	err := p.send(escpos.AppendResetBarCodeHeight(nil), nil)
 	if err != nil {
 		return fmt.Errorf("could not reset bar code height: %w", err)
 	}
@@ -590,43 +542,9 @@ func (p Printer) ResetBarCodeHeight() error {
 
 // SetBarCodeHeight sets the bar code height in n dots
 func (p Printer) SetBarCodeHeight(n int) error {
-	errMsg := "could not set bar code height: %w"
-
-	err := checkRange(n, 1, 255, "height")
-	if err != nil {
-		return fmt.Errorf(errMsg, err)
-	}
-
-	_, err = p.Write([]byte{GS, 'h', byte(n)})
+	err := p.send(escpos.AppendSetBarCodeHeight(nil, n))
 	if err != nil {
-		return fmt.Errorf(errMsg, err)
-	}
-
-	return nil
-}
-
-func checkBarcodeCodabarData(data string) error {
-	body := "0123456789-$:/.+"
-	wrappers := "ABCD"
-
-	if !strings.ContainsRune(wrappers, rune(data[0])) || !strings.ContainsRune(wrappers, rune(data[len(data)-1])) {
-		return fmt.Errorf("the first and last byte of CODABAR must be one of %s", wrappers)
-	}
-
-	for _, d := range data {
-		if !strings.ContainsRune(body, d) {
-			return fmt.Errorf("%s was in the bar code data and only %q is accepted", string(d), body)
-		}
-	}
-
-	return nil
-}
-
-func checkBarcodeData(data, accepted string) error {
-	for _, d := range data {
-		if !strings.ContainsRune(accepted, d) {
-			return fmt.Errorf("%s was in the bar code data and only %q is accepted", string(d), accepted)
-		}
+		return fmt.Errorf("could not set bar code height: %w", err)
 	}
 	return nil
 }
@@ -643,96 +561,28 @@ func checkBarcodeData(data, accepted string) error {
 //	BcITF: 0, 22
 //	BcCODABAR: 2, 19
 //	BcCODE93: 1, 17
-//	BcCODE123: 0, 65
+//	BcCODE128: 0, 65
 //
 // For the accepted data values:
 //
 //	BcUPCA, BcUPCE, BcJAN13, BcJAN8, BcITF all only accept [0123456789]
-//	BcCODE39, BcCODE93, BcCODE123 can accept [ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-.*$/+% ]
+//	BcCODE39, BcCODE93, BcCODE128 can accept [ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-.*$/+% ]
 //	BcCODABAR:
 //	  The first and last character of the CODABAR code bar has to be one of [ABCD]
 //	  and the rest of the characters in between can be one of [0123456789-$:/.+]
 //
-// Note on the CODE123 length:
+// Note on the CODE128 length:
 //
 //	...the docs say it's between 2 and 255 but the printer
 //	does not have that limit. On one hand it can go down to 0 character, but also I could
 //	not find the limit for max characters. At 15 characters it went off the page with a
 //	HOP-E802 printer and at 34 characters it starts printing the HRI weird. At 66 0s
 //	repeating it seems to break and stop printing, and the same at 65 As repeating.
-//	Long story short...I think they didn't finish programming the checks on CODE123
+//	Long story short...I think they didn't finish programming the checks on CODE128
 func (p Printer) PrintBarCode(barcodeType BarCode, data string) error {
-	errMsg := "could not print bar code: %w"
-
-	err := checkEnum(barcodeType, allBarcodes...)
-	if err != nil {
-		return fmt.Errorf(errMsg, err)
-	}
-
-	dataLength := len(data)
-
-	// Check length
-	var min, max int
-	switch barcodeType {
-	case BcUPCA:
-		min, max = 11, 12
-	case BcUPCE:
-		min, max = 6, 7
-	case BcJAN13:
-		min, max = 12, 13
-	case BcJAN8:
-		min, max = 7, 8
-	case BcCODE39:
-		min, max = 0, 14
-	case BcITF:
-		min, max = 0, 22
-	case BcCODABAR:
-		min, max = 2, 19
-	case BcCODE93:
-		min, max = 1, 17
-	case BcCODE123:
-		// At 66 characters for 'A...' the printer seems to cry
-		// for printing all 0s it cried at 65
-		// maybe it needs some friends
-		min, max = 0, 60
-	}
-
-	err = checkRange(dataLength, min, max, "data length")
-	if err != nil {
-		return fmt.Errorf(errMsg, err)
-	}
-
-	// Check data ranges
-	switch barcodeType {
-	case BcUPCA, BcUPCE, BcJAN13, BcJAN8, BcITF:
-		err = checkBarcodeData(data, "0123456789")
-	case BcCODE39, BcCODE93, BcCODE123:
-		err = checkBarcodeData(data, "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-.*$/+% ")
-	case BcCODABAR:
-		err = checkBarcodeCodabarData(data)
-	}
-
+	err := p.send(escpos.AppendBarCode(nil, barcodeType, data))
 	if err != nil {
-		return fmt.Errorf(errMsg, err)
+		return fmt.Errorf("could not print bar code: %w", err)
 	}
-
-	msg := []byte{0x1D, 'k', byte(barcodeType)}
-
-	// Add data
-	if inSlice(barcodeType, lengthBarcodes...) {
-		// length defined barcode
-		msg = append(msg, byte(dataLength))
-		msg = append(msg, data...)
-	} else {
-		// Null ending barcode
-		msg = append(msg, data...)
-		msg = append(msg, 0)
-	}
-
-	_, err = p.Write(msg)
-	if err != nil {
-		return fmt.Errorf(errMsg, err)
-	}
-
 	return nil
 }
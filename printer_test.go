@@ -0,0 +1,521 @@
+package hoin_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joeyak/hoin-printer"
+	"github.com/joeyak/hoin-printer/decoder"
+	"github.com/joeyak/hoin-printer/emulator"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+type goldenCase struct {
+	// name is the method name, optionally followed by an underscore and a
+	// variant
+	name string
+	run  func(p hoin.Printer) error
+}
+
+// testImage is a 20x30 image with a black border and a diagonal line so
+// every band has a mix of dots and the last band needs padding
+func testImage() image.Image {
+	img := image.NewGray(image.Rect(0, 0, 20, 30))
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 20; x++ {
+			c := color.Gray{Y: 0xFF}
+			if x == 0 || y == 0 || x == 19 || y == 29 || x == y {
+				c = color.Gray{}
+			}
+			img.SetGray(x, y, c)
+		}
+	}
+	return img
+}
+
+func testFont(t *testing.T) *opentype.Font {
+	f, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func goldenCases(t *testing.T) []goldenCase {
+	return []goldenCase{
+		{"Write", func(p hoin.Printer) error {
+			_, err := p.Write([]byte{0x01, 0x02, 0x03})
+			return err
+		}},
+		{"Read", func(p hoin.Printer) error {
+			_, err := p.Read(make([]byte, 1))
+			return err
+		}},
+		{"Close", func(p hoin.Printer) error { return p.Close() }},
+		{"Initialize", func(p hoin.Printer) error { return p.Initialize() }},
+
+		{"Beep", func(p hoin.Printer) error { return p.Beep(3, 9) }},
+		{"Beep_nTooLow", func(p hoin.Printer) error { return p.Beep(0, 1) }},
+		{"Beep_nTooHigh", func(p hoin.Printer) error { return p.Beep(10, 1) }},
+		{"Beep_tTooLow", func(p hoin.Printer) error { return p.Beep(1, 0) }},
+		{"Beep_tTooHigh", func(p hoin.Printer) error { return p.Beep(1, 10) }},
+
+		{"Print", func(p hoin.Printer) error { return p.Print("Hello", 1, true) }},
+		{"Print_Long", func(p hoin.Printer) error { return p.Print(strings.Repeat("0123456789", 150)) }},
+		{"Println", func(p hoin.Printer) error { return p.Println("Hello ", "World") }},
+		{"Printf", func(p hoin.Printer) error { return p.Printf("%03d|%-4s|", 7, "ab") }},
+
+		{"HT", func(p hoin.Printer) error { return p.HT() }},
+		{"LF", func(p hoin.Printer) error { return p.LF() }},
+		{"CR", func(p hoin.Printer) error { return p.CR() }},
+
+		{"Cut", func(p hoin.Printer) error { return p.Cut() }},
+		{"CutFeed", func(p hoin.Printer) error { return p.CutFeed(100) }},
+		{"CutFeed_TooLow", func(p hoin.Printer) error { return p.CutFeed(-1) }},
+		{"CutFeed_TooHigh", func(p hoin.Printer) error { return p.CutFeed(256) }},
+
+		{"ResetLineSpacing", func(p hoin.Printer) error { return p.ResetLineSpacing() }},
+		{"SetLineSpacing", func(p hoin.Printer) error { return p.SetLineSpacing(255) }},
+		{"SetLineSpacing_TooLow", func(p hoin.Printer) error { return p.SetLineSpacing(-1) }},
+		{"SetLineSpacing_TooHigh", func(p hoin.Printer) error { return p.SetLineSpacing(256) }},
+
+		{"Feed", func(p hoin.Printer) error { return p.Feed(50) }},
+		{"Feed_TooLow", func(p hoin.Printer) error { return p.Feed(-1) }},
+		{"Feed_TooHigh", func(p hoin.Printer) error { return p.Feed(256) }},
+		{"FeedLines", func(p hoin.Printer) error { return p.FeedLines(5) }},
+		{"FeedLines_TooLow", func(p hoin.Printer) error { return p.FeedLines(-1) }},
+		{"FeedLines_TooHigh", func(p hoin.Printer) error { return p.FeedLines(256) }},
+
+		{"SetHT", func(p hoin.Printer) error { return p.SetHT(8, 16, 255) }},
+		{"SetHT_Reset", func(p hoin.Printer) error { return p.SetHT() }},
+		{"SetHT_PositionTooLow", func(p hoin.Printer) error { return p.SetHT(8, 0) }},
+		{"SetHT_PositionTooHigh", func(p hoin.Printer) error { return p.SetHT(256) }},
+		{"SetHT_TooMany", func(p hoin.Printer) error { return p.SetHT(make([]int, 33)...) }},
+		{"SetTabs", func(p hoin.Printer) error { return p.SetTabs(4) }},
+		{"SetTabs_Wide", func(p hoin.Printer) error { return p.SetTabs(100) }},
+
+		{"SetBold_On", func(p hoin.Printer) error { return p.SetBold(true) }},
+		{"SetBold_Off", func(p hoin.Printer) error { return p.SetBold(false) }},
+		{"SetRotate90_On", func(p hoin.Printer) error { return p.SetRotate90(true) }},
+		{"SetRotate90_Off", func(p hoin.Printer) error { return p.SetRotate90(false) }},
+		{"SetReversePrinting_On", func(p hoin.Printer) error { return p.SetReversePrinting(true) }},
+		{"SetReversePrinting_Off", func(p hoin.Printer) error { return p.SetReversePrinting(false) }},
+
+		{"SetFont_A", func(p hoin.Printer) error { return p.SetFont(hoin.FontA) }},
+		{"SetFont_B", func(p hoin.Printer) error { return p.SetFont(hoin.FontB) }},
+		{"SetFont_Invalid", func(p hoin.Printer) error { return p.SetFont(2) }},
+
+		{"Justify_Left", func(p hoin.Printer) error { return p.Justify(hoin.LeftJustify) }},
+		{"Justify_Center", func(p hoin.Printer) error { return p.Justify(hoin.CenterJustify) }},
+		{"Justify_Right", func(p hoin.Printer) error { return p.Justify(hoin.RightJustify) }},
+		{"Justify_Invalid", func(p hoin.Printer) error { return p.Justify(3) }},
+
+		{"PrintImage8_Single", func(p hoin.Printer) error { return p.PrintImage8(testImage(), hoin.SingleDensity) }},
+		{"PrintImage8_Double", func(p hoin.Printer) error { return p.PrintImage8(testImage(), hoin.DoubleDensity) }},
+		{"PrintImage8_InvalidDensity", func(p hoin.Printer) error { return p.PrintImage8(testImage(), 2) }},
+		{"PrintImage24_Single", func(p hoin.Printer) error { return p.PrintImage24(testImage(), hoin.SingleDensity) }},
+		{"PrintImage24_Double", func(p hoin.Printer) error { return p.PrintImage24(testImage(), hoin.DoubleDensity) }},
+		{"PrintImage24_InvalidDensity", func(p hoin.Printer) error { return p.PrintImage24(testImage(), 2) }},
+
+		{"PrintText", func(p hoin.Printer) error {
+			return p.PrintText("Hi", testFont(t), hoin.TextOptions{Size: 24, Width: 48, Justify: hoin.CenterJustify})
+		}},
+		{"PrintText_InvalidSize", func(p hoin.Printer) error {
+			return p.PrintText("Hi", testFont(t), hoin.TextOptions{})
+		}},
+		{"PrintText_NegativeWidth", func(p hoin.Printer) error {
+			return p.PrintText("Hi", testFont(t), hoin.TextOptions{Size: 24, Width: -1})
+		}},
+		{"PrintText_InvalidJustify", func(p hoin.Printer) error {
+			return p.PrintText("Hi", testFont(t), hoin.TextOptions{Size: 24, Justify: 3})
+		}},
+		{"PrintBanner", func(p hoin.Printer) error {
+			return p.PrintBanner("I", hoin.BannerOptions{Font: testFont(t), HeightMM: 3})
+		}},
+		{"PrintBanner_TooTall", func(p hoin.Printer) error {
+			return p.PrintBanner("I", hoin.BannerOptions{HeightMM: 100})
+		}},
+		{"PrintBanner_Empty", func(p hoin.Printer) error {
+			return p.PrintBanner("", hoin.BannerOptions{HeightMM: 3})
+		}},
+
+		{"SetHRIPosition", func(p hoin.Printer) error { return p.SetHRIPosition(hoin.HRIBoth) }},
+		{"SetHRIPosition_Invalid", func(p hoin.Printer) error { return p.SetHRIPosition(4) }},
+		{"ResetBarCodeHeight", func(p hoin.Printer) error { return p.ResetBarCodeHeight() }},
+		{"SetBarCodeHeight", func(p hoin.Printer) error { return p.SetBarCodeHeight(80) }},
+		{"SetBarCodeHeight_TooLow", func(p hoin.Printer) error { return p.SetBarCodeHeight(0) }},
+		{"SetBarCodeHeight_TooHigh", func(p hoin.Printer) error { return p.SetBarCodeHeight(256) }},
+
+		{"PrintBarCode_UPCA", func(p hoin.Printer) error { return p.PrintBarCode(hoin.BcUPCA, "01234567890") }},
+		{"PrintBarCode_UPCE", func(p hoin.Printer) error { return p.PrintBarCode(hoin.BcUPCE, "0123456") }},
+		{"PrintBarCode_JAN13", func(p hoin.Printer) error { return p.PrintBarCode(hoin.BcJAN13, "4901234567894") }},
+		{"PrintBarCode_JAN8", func(p hoin.Printer) error { return p.PrintBarCode(hoin.BcJAN8, "4901234") }},
+		{"PrintBarCode_CODE39", func(p hoin.Printer) error { return p.PrintBarCode(hoin.BcCODE39, "HOIN-80 $1") }},
+		{"PrintBarCode_ITF", func(p hoin.Printer) error { return p.PrintBarCode(hoin.BcITF, "1234") }},
+		{"PrintBarCode_CODABAR", func(p hoin.Printer) error { return p.PrintBarCode(hoin.BcCODABAR, "A12-34B") }},
+		{"PrintBarCode_CODE93", func(p hoin.Printer) error { return p.PrintBarCode(hoin.BcCODE93, "CODE93") }},
+		{"PrintBarCode_CODE128", func(p hoin.Printer) error { return p.PrintBarCode(hoin.BcCODE128, "CODE128") }},
+		{"PrintBarCode_InvalidType", func(p hoin.Printer) error { return p.PrintBarCode(7, "1") }},
+		{"PrintBarCode_UPCATooShort", func(p hoin.Printer) error { return p.PrintBarCode(hoin.BcUPCA, "0123456789") }},
+		{"PrintBarCode_UPCATooLong", func(p hoin.Printer) error { return p.PrintBarCode(hoin.BcUPCA, "0123456789012") }},
+		{"PrintBarCode_CODE93Empty", func(p hoin.Printer) error { return p.PrintBarCode(hoin.BcCODE93, "") }},
+		{"PrintBarCode_CODE128TooLong", func(p hoin.Printer) error {
+			return p.PrintBarCode(hoin.BcCODE128, strings.Repeat("A", 61))
+		}},
+		{"PrintBarCode_JAN13Letters", func(p hoin.Printer) error { return p.PrintBarCode(hoin.BcJAN13, "49012345678AB") }},
+		{"PrintBarCode_CODE39Lowercase", func(p hoin.Printer) error { return p.PrintBarCode(hoin.BcCODE39, "hoin") }},
+		{"PrintBarCode_CODABARWrapper", func(p hoin.Printer) error { return p.PrintBarCode(hoin.BcCODABAR, "E1234A") }},
+		{"PrintBarCode_CODABARBody", func(p hoin.Printer) error { return p.PrintBarCode(hoin.BcCODABAR, "A12*4B") }},
+
+		{"TransmitPrinterStatus", func(p hoin.Printer) error {
+			_, err := p.TransmitPrinterStatus()
+			return err
+		}},
+		{"TransmitOfflineStatus", func(p hoin.Printer) error {
+			_, err := p.TransmitOfflineStatus()
+			return err
+		}},
+		{"TransmitErrorStatus", func(p hoin.Printer) error {
+			_, err := p.TransmitErrorStatus()
+			return err
+		}},
+		{"TransmitPaperSensorStatus", func(p hoin.Printer) error {
+			_, err := p.TransmitPaperSensorStatus()
+			return err
+		}},
+		{"Ready", func(p hoin.Printer) error { return p.Ready() }},
+		{"TransmitPrinterID", func(p hoin.Printer) error {
+			_, err := p.TransmitPrinterID(hoin.IDModelName)
+			return err
+		}},
+		{"TransmitPrinterID_Invalid", func(p hoin.Printer) error {
+			_, err := p.TransmitPrinterID(4)
+			return err
+		}},
+
+		{"Subscribe", func(p hoin.Printer) error {
+			_, unsubscribe := p.Subscribe()
+			unsubscribe()
+			return nil
+		}},
+
+		{"Transaction", func(p hoin.Printer) error {
+			return p.Transaction(func(tx hoin.Printer) error {
+				err := tx.Println("Total")
+				if err != nil {
+					return err
+				}
+				return tx.PrintBarCode(hoin.BcJAN13, "4901234567894")
+			})
+		}},
+		{"Transaction_Invalid", func(p hoin.Printer) error {
+			return p.Transaction(func(tx hoin.Printer) error {
+				err := tx.Println("Total")
+				if err != nil {
+					return err
+				}
+				return tx.PrintBarCode(hoin.BcJAN13, "49012345678AB")
+			})
+		}},
+		{"DryRun", func(p hoin.Printer) error {
+			_, err := p.DryRun(func(tx hoin.Printer) error { return tx.Println("Total") })
+			return err
+		}},
+
+		{"Flush", func(p hoin.Printer) error { return p.Flush() }},
+		{"WithContext", func(p hoin.Printer) error { return p.WithContext(context.Background()).Initialize() }},
+
+		{"Morse", func(p hoin.Printer) error { return p.Morse("e") }},
+		{"MorsePrint", func(p hoin.Printer) error { return p.MorsePrint("t") }},
+	}
+}
+
+// golden formats the result of a case for its golden file
+func golden(err error, written []byte) []byte {
+	var buf bytes.Buffer
+	if err != nil {
+		fmt.Fprintf(&buf, "error: %s\n", err)
+	}
+	buf.WriteString(hex.Dump(written))
+	return buf.Bytes()
+}
+
+// listing decodes written bytes to make failures readable
+func listing(written []byte) string {
+	var lines []string
+	for _, c := range decoder.Decode(written) {
+		lines = append(lines, c.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestGolden(t *testing.T) {
+	for _, tc := range goldenCases(t) {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			device := emulator.NewDevice()
+			err := tc.run(hoin.NewPrinter(device))
+			got := golden(err, device.Bytes())
+
+			// Validation happens before anything is sent
+			if err != nil && tc.name != "Read" && len(device.Bytes()) > 0 {
+				t.Errorf("%d bytes were written before failing with: %s", len(device.Bytes()), err)
+			}
+
+			path := filepath.Join("testdata", "golden", tc.name+".golden")
+			if *update {
+				err := os.WriteFile(path, got, 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("could not read golden file, run go test -update: %s", err)
+			}
+
+			if !bytes.Equal(got, want) {
+				t.Errorf("output does not match %s\ngot:\n%s\nwant:\n%s\ncommands:\n%s", path, got, want, listing(device.Bytes()))
+			}
+		})
+	}
+}
+
+// TestGoldenCoverage makes sure every exported method has a golden case
+func TestGoldenCoverage(t *testing.T) {
+	covered := map[string]bool{}
+	for _, tc := range goldenCases(t) {
+		covered[strings.SplitN(tc.name, "_", 2)[0]] = true
+	}
+
+	typ := reflect.TypeOf(hoin.Printer{})
+	for i := 0; i < typ.NumMethod(); i++ {
+		name := typ.Method(i).Name
+		if !covered[name] {
+			t.Errorf("Printer.%s has no golden case", name)
+		}
+	}
+}
+
+func TestWriteFailure(t *testing.T) {
+	failure := errors.New("connection reset")
+
+	device := emulator.NewDevice()
+	device.FailAt(0, failure)
+
+	err := hoin.NewPrinter(device).Initialize()
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected %q to wrap %q", err, failure)
+	}
+}
+
+func TestImageWriteFailure(t *testing.T) {
+	failure := errors.New("connection reset")
+
+	// Fail part way through the second band
+	device := emulator.NewDevice()
+	device.FailAt(100, failure)
+
+	err := hoin.NewPrinter(device).PrintImage24(testImage(), hoin.DoubleDensity)
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected %q to wrap %q", err, failure)
+	}
+
+	if n := len(device.Bytes()); n != 100 {
+		t.Errorf("expected 100 bytes to be written but got %d", n)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	device := emulator.NewDevice()
+	printer := hoin.NewPrinter(device)
+
+	device.SetStatus(emulator.Status{
+		DrawerOpen:           true,
+		CoverOpen:            true,
+		PrintingStopped:      true,
+		CutterError:          true,
+		AutoRecoverableError: true,
+		PaperOut:             true,
+	})
+
+	printerStatus, err := printer.TransmitPrinterStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (hoin.PrinterStatus{DrawerOpen: true}); printerStatus != want {
+		t.Errorf("got %+v want %+v", printerStatus, want)
+	}
+
+	offlineStatus, err := printer.TransmitOfflineStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (hoin.OfflineStatus{CoverOpen: true, PrintingStopped: true}); offlineStatus != want {
+		t.Errorf("got %+v want %+v", offlineStatus, want)
+	}
+
+	errorStatus, err := printer.TransmitErrorStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (hoin.ErrorStatus{AutoCutter: true, AutoRecoverable: true}); errorStatus != want {
+		t.Errorf("got %+v want %+v", errorStatus, want)
+	}
+
+	paperStatus, err := printer.TransmitPaperSensorStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (hoin.PaperSensorStatus{RollEnd: true}); paperStatus != want {
+		t.Errorf("got %+v want %+v", paperStatus, want)
+	}
+}
+
+func TestReady(t *testing.T) {
+	device := emulator.NewDevice()
+	printer := hoin.NewPrinter(device)
+
+	err := printer.Ready()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	device.SetStatus(emulator.Status{PaperOut: true})
+	err = printer.Ready()
+	if !errors.Is(err, hoin.ErrPaperOut) {
+		t.Errorf("expected %q to be %q", err, hoin.ErrPaperOut)
+	}
+
+	device.SetStatus(emulator.Status{CoverOpen: true, PaperOut: true})
+	err = printer.Ready()
+	if !errors.Is(err, hoin.ErrCoverOpen) {
+		t.Errorf("expected %q to be %q", err, hoin.ErrCoverOpen)
+	}
+}
+
+func TestImagePaperOut(t *testing.T) {
+	// The paper runs out during the first band
+	device := emulator.NewDevice()
+	device.At(10, func(s *emulator.Status) { s.PaperOut = true })
+
+	printer := hoin.NewPrinter(device, hoin.WithFlowControl(hoin.ReadyPolling()))
+	err := printer.PrintImage24(testImage(), hoin.DoubleDensity)
+	if !errors.Is(err, hoin.ErrPaperOut) {
+		t.Fatalf("expected %q to be %q", err, hoin.ErrPaperOut)
+	}
+
+	// Nothing is sent after the status request following the first band
+	commands := decoder.Decode(device.Bytes())
+	if last := commands[len(commands)-1]; last.Name != "DLE EOT" || last.Offset > 100 {
+		t.Errorf("expected the image to stop after the first band but got %v", commands)
+	}
+}
+
+func TestReadyPollingCoverOpen(t *testing.T) {
+	device := emulator.NewDevice()
+	device.SetStatus(emulator.Status{CoverOpen: true})
+
+	printer := hoin.NewPrinter(device, hoin.WithFlowControl(hoin.ReadyPolling()))
+	err := printer.PrintImage8(testImage(), hoin.SingleDensity)
+	if !errors.Is(err, hoin.ErrCoverOpen) {
+		t.Errorf("expected %q to be %q", err, hoin.ErrCoverOpen)
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	printer := hoin.NewPrinter(emulator.NewDevice())
+
+	var rangeErr *hoin.RangeError
+	err := printer.Feed(256)
+	if !errors.As(err, &rangeErr) || rangeErr.Value != 256 {
+		t.Errorf("expected %q to be a range error", err)
+	}
+	err = printer.SetHT(make([]int, 33)...)
+	if !errors.As(err, &rangeErr) || rangeErr.Max != 32 || rangeErr.Value != 33 {
+		t.Errorf("expected %q to be a range error", err)
+	}
+	err = printer.PrintBanner("Hi", hoin.BannerOptions{HeightMM: 100})
+	if !errors.As(err, &rangeErr) || rangeErr.Field != "height in dots" {
+		t.Errorf("expected %q to be a range error", err)
+	}
+
+	var enumErr *hoin.EnumError
+	err = printer.Justify(3)
+	if !errors.As(err, &enumErr) {
+		t.Errorf("expected %q to be an enum error", err)
+	}
+
+	var dataErr *hoin.BarcodeDataError
+	err = printer.PrintBarCode(hoin.BcJAN13, "49012345678AB")
+	if !errors.As(err, &dataErr) || dataErr.Position != 11 || dataErr.Rune != 'A' {
+		t.Errorf("expected %q to be a bar code data error at position 11", err)
+	}
+}
+
+func TestStatusChangeAtOffset(t *testing.T) {
+	device := emulator.NewDevice()
+	printer := hoin.NewPrinter(device)
+
+	device.At(10, func(s *emulator.Status) { s.PaperNearEnd = true })
+
+	status, err := printer.TransmitPaperSensorStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.NearEnd {
+		t.Error("paper was near the end before 10 bytes were written")
+	}
+
+	err = printer.Print("0123456789")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err = printer.TransmitPaperSensorStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.NearEnd {
+		t.Error("paper was not near the end after 10 bytes were written")
+	}
+}
+
+func TestPrinterID(t *testing.T) {
+	device := emulator.NewDevice()
+	printer := hoin.NewPrinter(device)
+
+	tests := []struct {
+		n    hoin.PrinterIDType
+		want string
+	}{
+		{hoin.IDType, "\x02"},
+		{hoin.IDManufacturer, "HOIN"},
+		{hoin.IDModelName, "HOP-E802"},
+	}
+	for _, tt := range tests {
+		id, err := printer.TransmitPrinterID(tt.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(id) != tt.want {
+			t.Errorf("expected ID %d to be %q but got %q", tt.n, tt.want, id)
+		}
+	}
+}
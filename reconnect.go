@@ -0,0 +1,436 @@
+package hoin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/joeyak/hoin-printer/decoder"
+)
+
+// ReconnectOptions controls how a dropped connection is redialled
+type ReconnectOptions struct {
+	// Attempts is how many times to redial before giving up.  5 is used when
+	// Attempts is 0.
+	Attempts int
+	// Backoff is the wait before the second attempt, doubling after every
+	// attempt after that.  The first attempt is straight away.  100ms is
+	// used when Backoff is 0.
+	Backoff time.Duration
+	// MaxBackoff caps the wait between attempts.  5s is used when MaxBackoff
+	// is 0.
+	MaxBackoff time.Duration
+}
+
+// WithReconnect redials the printer when its connection drops.  It is only
+// used by NewIpPrinter and NewIpPrinterContext.
+//
+// After redialling the printer is initialized and the tab stops, line
+// spacing, code page and text styles sent before the drop are sent again.
+// The write or read that found the dropped connection fails with a
+// *ReconnectError, which says if the job may have been partially printed,
+// and everything after it goes to the new connection.
+//
+// Redialling gives up early when the printer is closed or the deadline of
+// the write or read that found the drop passes, including when the context
+// from WithContext is cancelled.
+func WithReconnect(opts ReconnectOptions) Option {
+	return func(p *Printer) {
+		p.reconnect = &opts
+	}
+}
+
+// ReconnectError is returned when the connection to the printer dropped
+type ReconnectError struct {
+	// Err is why the connection dropped
+	Err error
+	// Reconnected is true when the printer was redialled and its state
+	// restored.  When false the next write or read tries again.
+	Reconnected bool
+	// Partial is true when some of the job since the last cut was sent
+	// before the connection dropped, so part of it may have been printed
+	Partial bool
+}
+
+func (e *ReconnectError) Error() string {
+	state := "could not reconnect"
+	if e.Reconnected {
+		state = "reconnected"
+	}
+	if e.Partial {
+		state += ", job may be partially printed"
+	}
+	return fmt.Sprintf("connection to printer dropped (%s): %s", state, e.Err)
+}
+
+func (e *ReconnectError) Unwrap() error {
+	return e.Err
+}
+
+// trackedCommands are the commands whose last value is sent again after
+// reconnecting, in the order they are sent
+var trackedCommands = []string{
+	"ESC t", // code page
+	"ESC D", // tab stops
+	"ESC 3", // line spacing
+	"ESC M", // font
+	"ESC a", // justification
+	"ESC E", // bold
+	"ESC G", // double-strike
+	"ESC V", // 90 degree rotation
+	"GS B",  // reverse printing
+	"GS H",  // HRI position
+	"GS h",  // bar code height
+}
+
+// reconnector is a connection to a network printer that redials when it
+// drops
+type reconnector struct {
+	addr string
+	opts ReconnectOptions
+
+	// ctx is cancelled by Close to stop dialling
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// writeMu keeps writes in order without holding mu while they block
+	writeMu sync.Mutex
+
+	mu     sync.Mutex
+	conn   net.Conn
+	closed bool
+	// redialled is closed when the redial in progress finishes, nil when
+	// there isn't one
+	redialled chan struct{}
+	// wake is closed when a deadline changes or the reconnector is closed so
+	// a backoff sleep notices straight away
+	wake chan struct{}
+	// state is the last raw bytes of each tracked command
+	state map[string][]byte
+	// tail is an incomplete command at the end of the last write
+	tail []byte
+	// sinceCut counts the bytes written since the last cut
+	sinceCut      int
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+func newReconnector(conn net.Conn, addr string, opts ReconnectOptions) *reconnector {
+	if opts.Attempts == 0 {
+		opts.Attempts = 5
+	}
+	if opts.Backoff == 0 {
+		opts.Backoff = 100 * time.Millisecond
+	}
+	if opts.MaxBackoff == 0 {
+		opts.MaxBackoff = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &reconnector{
+		addr:   addr,
+		opts:   opts,
+		ctx:    ctx,
+		cancel: cancel,
+		conn:   conn,
+		state:  map[string][]byte{},
+		wake:   make(chan struct{}),
+	}
+}
+
+// wakeSleep wakes a backoff sleep.  r.mu must be held.
+func (r *reconnector) wakeSleep() {
+	close(r.wake)
+	r.wake = make(chan struct{})
+}
+
+// track records the state set by the commands in b
+func (r *reconnector) track(b []byte) {
+	data := append(r.tail, b...)
+	r.tail = nil
+
+	for _, c := range decoder.Decode(data) {
+		if c.Error == "truncated command" {
+			r.tail = append([]byte{}, c.Raw...)
+			break
+		}
+
+		switch {
+		case c.Name == "ESC @":
+			r.state = map[string][]byte{}
+		case c.Name == "ESC 2":
+			delete(r.state, "ESC 3")
+		case c.Name == "GS V":
+			r.sinceCut = 0
+			continue
+		case c.Error == "" && inSlice(c.Name, trackedCommands...):
+			r.state[c.Name] = append([]byte{}, c.Raw...)
+		}
+		r.sinceCut += len(c.Raw)
+	}
+}
+
+func inSlice[T comparable](v T, s ...T) bool {
+	for _, a := range s {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
+
+// restore initializes the printer and sends the tracked state
+func (r *reconnector) restore() []byte {
+	data := []byte{ESC, '@'}
+	for _, name := range trackedCommands {
+		data = append(data, r.state[name]...)
+	}
+	return data
+}
+
+// stopped returns why redialling should stop, if it should.  r.mu must be
+// held.
+func (r *reconnector) stopped(deadline *time.Time) error {
+	if r.closed {
+		return net.ErrClosed
+	}
+	if !deadline.IsZero() && !time.Now().Before(*deadline) {
+		return os.ErrDeadlineExceeded
+	}
+	return nil
+}
+
+// sleep waits for d with r.mu released, ending early when redialling should
+// stop
+func (r *reconnector) sleep(d time.Duration, deadline *time.Time) error {
+	until := time.Now().Add(d)
+	for {
+		err := r.stopped(deadline)
+		if err != nil {
+			return err
+		}
+
+		wait := time.Until(until)
+		if wait <= 0 {
+			return nil
+		}
+		if !deadline.IsZero() && time.Until(*deadline) < wait {
+			wait = time.Until(*deadline)
+		}
+
+		wake := r.wake
+		r.mu.Unlock()
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-wake:
+		}
+		timer.Stop()
+		r.mu.Lock()
+	}
+}
+
+// dial connects to the printer with r.mu released
+func (r *reconnector) dial(deadline time.Time) (net.Conn, error) {
+	r.mu.Unlock()
+	defer r.mu.Lock()
+
+	dialer := net.Dialer{Timeout: DefaultDialTimeout, Deadline: deadline}
+	return dialer.DialContext(r.ctx, "tcp", r.addr)
+}
+
+// redial connects again with backoff and restores the printer state.  r.mu
+// must be held and is released while sleeping and dialling, so Close and
+// the deadlines aren't blocked.  Redialling stops when deadline passes or
+// the reconnector is closed.
+func (r *reconnector) redial(deadline *time.Time) error {
+	// Only one redial runs at a time, anything else waits for it
+	for r.redialled != nil {
+		redialled := r.redialled
+		r.mu.Unlock()
+		<-redialled
+		r.mu.Lock()
+
+		if r.conn != nil {
+			return nil
+		}
+	}
+
+	if r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+
+	redialled := make(chan struct{})
+	r.redialled = redialled
+	defer func() {
+		r.redialled = nil
+		close(redialled)
+	}()
+
+	backoff := r.opts.Backoff
+	var err error
+	for attempt := 0; attempt < r.opts.Attempts; attempt++ {
+		if attempt > 0 {
+			stopErr := r.sleep(backoff, deadline)
+			if stopErr != nil {
+				return fmt.Errorf("stopped after %d attempts: %w", attempt, stopErr)
+			}
+			backoff *= 2
+			if backoff > r.opts.MaxBackoff {
+				backoff = r.opts.MaxBackoff
+			}
+		}
+
+		var conn net.Conn
+		conn, err = r.dial(*deadline)
+		if stopErr := r.stopped(deadline); stopErr != nil {
+			if conn != nil {
+				conn.Close()
+			}
+			return fmt.Errorf("stopped after %d attempts: %w", attempt+1, stopErr)
+		}
+		if err != nil {
+			continue
+		}
+
+		conn.SetReadDeadline(r.readDeadline)
+		conn.SetWriteDeadline(r.writeDeadline)
+
+		_, err = conn.Write(r.restore())
+		if err != nil {
+			conn.Close()
+			continue
+		}
+
+		r.conn = conn
+		r.tail = nil
+		r.sinceCut = 0
+		return nil
+	}
+	return fmt.Errorf("gave up after %d attempts: %w", r.opts.Attempts, err)
+}
+
+// dropped reports whether err means the connection has gone, as opposed to
+// a deadline passing
+func dropped(err error) bool {
+	return err != nil && !errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// handleDrop redials after err dropped the connection and returns the error
+// to give the caller
+func (r *reconnector) handleDrop(err error, partial bool, deadline *time.Time) error {
+	reconnectErr := &ReconnectError{Err: err, Partial: partial}
+	if redialErr := r.redial(deadline); redialErr != nil {
+		reconnectErr.Err = fmt.Errorf("%s, then %w", err, redialErr)
+		return reconnectErr
+	}
+	reconnectErr.Reconnected = true
+	return reconnectErr
+}
+
+func (r *reconnector) Write(b []byte) (int, error) {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return 0, net.ErrClosed
+	}
+	if r.conn == nil {
+		err := r.redial(&r.writeDeadline)
+		if err != nil {
+			r.mu.Unlock()
+			return 0, &ReconnectError{Err: err}
+		}
+	}
+	conn := r.conn
+	r.mu.Unlock()
+
+	// Writes aren't locked either so a stalled write can still have its
+	// deadline moved or be closed
+	n, err := conn.Write(b)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.track(b[:n])
+	if !dropped(err) || r.closed {
+		return n, err
+	}
+	if r.conn != conn {
+		// A read already reconnected
+		return n, &ReconnectError{Err: err, Reconnected: r.conn != nil, Partial: r.sinceCut > 0}
+	}
+	return n, r.handleDrop(err, r.sinceCut > 0, &r.writeDeadline)
+}
+
+func (r *reconnector) Read(b []byte) (int, error) {
+	r.mu.Lock()
+	conn := r.conn
+	r.mu.Unlock()
+
+	if conn == nil {
+		return 0, &ReconnectError{Err: errors.New("not connected")}
+	}
+
+	// Reads aren't locked so a blocked status read doesn't stop Close
+	n, err := conn.Read(b)
+	if !dropped(err) {
+		return n, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return n, err
+	}
+	if r.conn != conn {
+		// A write already reconnected
+		return n, &ReconnectError{Err: err, Reconnected: r.conn != nil}
+	}
+	return n, r.handleDrop(err, r.sinceCut > 0, &r.readDeadline)
+}
+
+func (r *reconnector) SetReadDeadline(t time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.readDeadline = t
+	r.wakeSleep()
+	if r.conn == nil {
+		return nil
+	}
+	return r.conn.SetReadDeadline(t)
+}
+
+func (r *reconnector) SetWriteDeadline(t time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.writeDeadline = t
+	r.wakeSleep()
+	if r.conn == nil {
+		return nil
+	}
+	return r.conn.SetWriteDeadline(t)
+}
+
+func (r *reconnector) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	r.cancel()
+	r.wakeSleep()
+	if r.conn == nil {
+		return nil
+	}
+	return r.conn.Close()
+}
@@ -0,0 +1,300 @@
+package hoin_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/joeyak/hoin-printer"
+)
+
+// listenPrinter listens for connections to a printer and sends each one on
+// the returned channel
+func listenPrinter(t *testing.T) (net.Listener, <-chan net.Conn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	conns := make(chan net.Conn, 4)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			conns <- conn
+		}
+	}()
+	return listener, conns
+}
+
+func accept(t *testing.T, conns <-chan net.Conn) net.Conn {
+	select {
+	case conn := <-conns:
+		return conn
+	case <-time.After(time.Second):
+		t.Fatal("printer was never dialled")
+		return nil
+	}
+}
+
+func readExactly(t *testing.T, conn net.Conn, expected []byte) {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	got := make([]byte, len(expected))
+	_, err := io.ReadFull(conn, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Errorf("expected the printer to get %q but got %q", expected, got)
+	}
+}
+
+// dropPrinter prints until the printer notices the dropped connection
+func dropPrinter(t *testing.T, printer hoin.Printer) *hoin.ReconnectError {
+	for i := 0; i < 100; i++ {
+		err := printer.Print("x")
+		if err == nil {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+
+		var reconnectErr *hoin.ReconnectError
+		if !errors.As(err, &reconnectErr) {
+			t.Fatalf("expected %q to be a reconnect error", err)
+		}
+		return reconnectErr
+	}
+	t.Fatal("printer never noticed the connection drop")
+	return nil
+}
+
+func TestReconnect(t *testing.T) {
+	listener, conns := listenPrinter(t)
+
+	printer, err := hoin.NewIpPrinter(listener.Addr().String(),
+		hoin.WithFlowControl(hoin.NoFlowControl()),
+		hoin.WithReconnect(hoin.ReconnectOptions{Backoff: 10 * time.Millisecond}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer printer.Close()
+
+	first := accept(t, conns)
+
+	err = printer.SetHT(8, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = printer.SetLineSpacing(40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = printer.Print("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readExactly(t, first, []byte("\x1bD\x08\x10\x00\x1b3\x28abc"))
+	first.Close()
+
+	reconnectErr := dropPrinter(t, printer)
+	if !reconnectErr.Reconnected {
+		t.Errorf("expected %q to have reconnected", reconnectErr)
+	}
+	if !reconnectErr.Partial {
+		t.Errorf("expected %q to be a partial job", reconnectErr)
+	}
+
+	second := accept(t, conns)
+	readExactly(t, second, []byte("\x1b@\x1bD\x08\x10\x00\x1b3\x28"))
+
+	// The new connection is used for everything after
+	err = printer.Print("def")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readExactly(t, second, []byte("def"))
+}
+
+func TestReconnectGivesUp(t *testing.T) {
+	listener, conns := listenPrinter(t)
+
+	printer, err := hoin.NewIpPrinter(listener.Addr().String(),
+		hoin.WithFlowControl(hoin.NoFlowControl()),
+		hoin.WithReconnect(hoin.ReconnectOptions{Attempts: 2, Backoff: time.Millisecond}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer printer.Close()
+
+	accept(t, conns).Close()
+	listener.Close()
+
+	reconnectErr := dropPrinter(t, printer)
+	if reconnectErr.Reconnected {
+		t.Errorf("expected %q to not reconnect", reconnectErr)
+	}
+}
+
+// dropDuringBackoff connects a printer, drops the connection and stops
+// listening so redialling backs off for a long time
+func dropDuringBackoff(t *testing.T) hoin.Printer {
+	listener, conns := listenPrinter(t)
+
+	printer, err := hoin.NewIpPrinter(listener.Addr().String(),
+		hoin.WithFlowControl(hoin.NoFlowControl()),
+		hoin.WithReconnect(hoin.ReconnectOptions{Backoff: time.Hour, MaxBackoff: time.Hour}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accept(t, conns).Close()
+	listener.Close()
+	return printer
+}
+
+// printUntilError prints in the background and sends the first error
+func printUntilError(printer hoin.Printer) <-chan error {
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			err := printer.Print("x")
+			if err != nil {
+				errs <- err
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	return errs
+}
+
+func TestReconnectClose(t *testing.T) {
+	printer := dropDuringBackoff(t)
+	errs := printUntilError(printer)
+
+	// Give the drop time to be found so Close happens during the backoff
+	time.Sleep(100 * time.Millisecond)
+	err := printer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errs:
+		var reconnectErr *hoin.ReconnectError
+		if !errors.As(err, &reconnectErr) || reconnectErr.Reconnected || !errors.Is(err, net.ErrClosed) {
+			t.Errorf("expected %q to stop redialling because it was closed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("closing did not stop redialling")
+	}
+}
+
+func TestReconnectContext(t *testing.T) {
+	printer := dropDuringBackoff(t)
+	defer printer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := printUntilError(printer.WithContext(ctx))
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected %q to stop redialling because the context was cancelled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelling the context did not stop redialling")
+	}
+}
+
+// blockedWrite starts a write to a printer that never reads, which blocks
+// once the socket buffers are full
+func blockedWrite(t *testing.T, printer hoin.Printer) <-chan error {
+	errs := make(chan error, 1)
+	go func() {
+		_, err := printer.Write(bytes.Repeat([]byte("x"), 64<<20))
+		errs <- err
+	}()
+
+	// Give the write time to fill the buffers
+	time.Sleep(200 * time.Millisecond)
+	return errs
+}
+
+func connectNotReading(t *testing.T) hoin.Printer {
+	listener, conns := listenPrinter(t)
+
+	printer, err := hoin.NewIpPrinter(listener.Addr().String(),
+		hoin.WithFlowControl(hoin.NoFlowControl()),
+		hoin.WithReconnect(hoin.ReconnectOptions{}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accept(t, conns)
+	return printer
+}
+
+func TestReconnectCancelBlockedWrite(t *testing.T) {
+	printer := connectNotReading(t)
+	defer printer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := blockedWrite(t, printer.WithContext(ctx))
+
+	cancelled := make(chan struct{})
+	go func() {
+		cancel()
+		close(cancelled)
+	}()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected %q to be %q", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelling the context did not stop the write")
+	}
+	<-cancelled
+}
+
+func TestReconnectCloseBlockedWrite(t *testing.T) {
+	printer := connectNotReading(t)
+	errs := blockedWrite(t, printer)
+
+	closed := make(chan error, 1)
+	go func() { closed <- printer.Close() }()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("close was blocked by the write")
+	}
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("expected the write to fail once the printer was closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("closing did not stop the write")
+	}
+}
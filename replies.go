@@ -0,0 +1,477 @@
+package hoin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/joeyak/hoin-printer/escpos"
+)
+
+// ReplyKind is what a reply from the printer answers, told apart by the
+// fixed bits of its first byte
+type ReplyKind int
+
+const (
+	// ReplyUnknown is a byte that doesn't match any reply, like a stray
+	// byte from a transport that was reconnected
+	ReplyUnknown ReplyKind = iota
+	// ReplyStatus is the one byte answer to DLE EOT
+	ReplyStatus
+	// ReplyAutoStatus is a four byte Automatic Status Back block, which the
+	// printer sends by itself when its status changes if enabled with GS a
+	ReplyAutoStatus
+	// ReplyID is the answer to GS I.  Single byte IDs are sent as is, text
+	// IDs without their header and NUL.
+	ReplyID
+	// ReplyXon and ReplyXoff are the flow control bytes
+	ReplyXon
+	ReplyXoff
+)
+
+func (k ReplyKind) String() string {
+	switch k {
+	case ReplyStatus:
+		return "status"
+	case ReplyAutoStatus:
+		return "automatic status"
+	case ReplyID:
+		return "printer ID"
+	case ReplyXon:
+		return "XON"
+	case ReplyXoff:
+		return "XOFF"
+	}
+	return "unknown"
+}
+
+// Reply is a complete reply read from the printer
+type Reply struct {
+	Kind ReplyKind
+	Data []byte
+}
+
+// idHeader starts a text ID reply, which ends with NUL
+const idHeader = 0x5F
+
+// replySize is how many bytes are read at once
+const replySize = 64
+
+// subscriberSize is how many replies a subscriber can fall behind by before
+// replies to it are dropped
+const subscriberSize = 16
+
+type replyResult struct {
+	data []byte
+	err  error
+}
+
+// replies reads from the printer in the background while anything is
+// waiting on it and hands each reply to the oldest request waiting for its
+// kind, or to the subscribers if nothing is
+type replies struct {
+	// sendMu keeps requests in the same order as they are written
+	sendMu sync.Mutex
+
+	mu          sync.Mutex
+	reading     bool
+	waiting     map[ReplyKind][]chan replyResult
+	subscribers map[chan Reply]bool
+	// stopped is closed when the reader exits
+	stopped chan struct{}
+
+	// setDeadline is the read deadline of the transport, nil when it can't
+	// have one.  The reader sets it to give up on a read when nothing is
+	// waiting, or at pollUntil to check for XOFF.
+	setDeadline func(time.Time) error
+	deadlineSet bool
+	pollUntil   time.Time
+
+	// started counts the reads started and done is the last one finished,
+	// with readDone closed and replaced every time one finishes
+	started, done int
+	readDone      chan struct{}
+
+	// partial is a reply that hasn't been read completely
+	partial     []byte
+	partialKind ReplyKind
+
+	// paused is set by XOFF and cleared by XON, with flowChanged closed
+	// and replaced on every change
+	paused      bool
+	flowChanged chan struct{}
+	flowWaiters int
+	// err is why the reader last stopped
+	err error
+}
+
+func newReplies() *replies {
+	return &replies{
+		waiting:     map[ReplyKind][]chan replyResult{},
+		subscribers: map[chan Reply]bool{},
+		flowChanged: make(chan struct{}),
+		readDone:    make(chan struct{}),
+	}
+}
+
+// wanted reports whether anything is waiting on the reader
+func (r *replies) wanted() bool {
+	for _, waiting := range r.waiting {
+		if len(waiting) > 0 {
+			return true
+		}
+	}
+	return len(r.subscribers) > 0 || r.flowWaiters > 0
+}
+
+// start runs the reader if it isn't already.  r.mu must be held.
+func (r *replies) start(src io.Reader) {
+	if r.reading {
+		return
+	}
+	r.reading = true
+	r.err = nil
+	r.stopped = make(chan struct{})
+
+	r.setDeadline = nil
+	r.deadlineSet = false
+	if deadliner, ok := src.(readDeadliner); ok {
+		r.setDeadline = deadliner.SetReadDeadline
+	}
+
+	go r.read(src)
+}
+
+func (r *replies) read(src io.Reader) {
+	b := make([]byte, replySize)
+	for {
+		r.mu.Lock()
+		if !r.wanted() {
+			r.finish()
+			r.mu.Unlock()
+			return
+		}
+		r.started++
+		read := r.started
+		r.armDeadline()
+		r.mu.Unlock()
+
+		n, err := src.Read(b)
+
+		r.mu.Lock()
+		for _, c := range b[:n] {
+			r.feed(c)
+		}
+		r.done = read
+		close(r.readDone)
+		r.readDone = make(chan struct{})
+
+		if err != nil && !(r.deadlineSet && errors.Is(err, os.ErrDeadlineExceeded)) {
+			r.stop(err)
+			r.finish()
+			r.mu.Unlock()
+			return
+		}
+		r.mu.Unlock()
+	}
+}
+
+// armDeadline sets the read deadline for the next read, which only has one
+// while polling.  r.mu must be held.
+func (r *replies) armDeadline() {
+	if r.setDeadline == nil {
+		return
+	}
+
+	var deadline time.Time
+	if time.Now().Before(r.pollUntil) {
+		deadline = r.pollUntil
+	} else if !r.deadlineSet {
+		return
+	}
+	r.deadlineOrDisable(deadline)
+}
+
+// interrupt makes the read in progress give up at t, returning false when
+// the transport can't have a read deadline.  r.mu must be held.
+func (r *replies) interrupt(t time.Time) bool {
+	if !r.reading || r.setDeadline == nil {
+		return false
+	}
+	return r.deadlineOrDisable(t)
+}
+
+// deadlineOrDisable sets the read deadline, giving up on deadlines when the
+// transport turns out not to support them.  r.mu must be held.
+func (r *replies) deadlineOrDisable(t time.Time) bool {
+	err := r.setDeadline(t)
+	if err != nil {
+		r.setDeadline = nil
+		r.deadlineSet = false
+		return false
+	}
+	r.deadlineSet = !t.IsZero()
+	return true
+}
+
+// release stops the reader once nothing is waiting on it.  r.mu must be
+// held.
+func (r *replies) release() {
+	if !r.wanted() {
+		r.interrupt(time.Now())
+	}
+}
+
+// finish marks the reader as stopped.  r.mu must be held.
+func (r *replies) finish() {
+	if r.deadlineSet {
+		r.setDeadline(time.Time{})
+		r.deadlineSet = false
+	}
+	r.reading = false
+	close(r.stopped)
+}
+
+// ErrReadPending is returned when reading from the printer directly while
+// the background reader is still blocked in a read it can't give up on,
+// which happens after a request times out on a transport without read
+// deadlines.  The reader stops once the printer sends anything, so the read
+// can be tried again then.
+var ErrReadPending = errors.New("a background read from the printer can't be interrupted")
+
+// idle waits for the reader to stop so the transport can be read directly,
+// failing if anything is still waiting on it or the read in progress can't
+// be interrupted
+func (p Printer) idle() error {
+	r := p.replies
+
+	r.mu.Lock()
+	if !r.reading {
+		r.mu.Unlock()
+		return nil
+	}
+	if r.wanted() {
+		r.mu.Unlock()
+		return errors.New("replies are being read in the background")
+	}
+	stopped := r.stopped
+	if !r.interrupt(time.Now()) && r.started > r.done {
+		r.mu.Unlock()
+		return ErrReadPending
+	}
+	r.mu.Unlock()
+
+	var done <-chan struct{}
+	if p.ctx != nil {
+		done = p.ctx.Done()
+	}
+
+	select {
+	case <-stopped:
+		return nil
+	case <-done:
+		return p.contextErr()
+	}
+}
+
+// stop fails every waiting request and closes the subscribers after a read
+// error.  r.mu must be held.
+func (r *replies) stop(err error) {
+	r.err = err
+	r.partial = nil
+
+	for kind, waiting := range r.waiting {
+		for _, ch := range waiting {
+			ch <- replyResult{err: err}
+		}
+		delete(r.waiting, kind)
+	}
+
+	for ch := range r.subscribers {
+		close(ch)
+		delete(r.subscribers, ch)
+	}
+}
+
+// feed classifies the next byte from the printer.  r.mu must be held.
+func (r *replies) feed(c byte) {
+	if r.partial != nil {
+		r.partial = append(r.partial, c)
+
+		switch {
+		case r.partialKind == ReplyID && c == escpos.NUL:
+			r.route(Reply{ReplyID, r.partial[1 : len(r.partial)-1]})
+			r.partial = nil
+		case r.partialKind == ReplyAutoStatus && len(r.partial) == 4:
+			r.route(Reply{ReplyAutoStatus, r.partial})
+			r.partial = nil
+		}
+		return
+	}
+
+	switch {
+	case c == XON:
+		r.setPaused(false)
+		r.route(Reply{ReplyXon, []byte{c}})
+	case c == XOFF:
+		r.setPaused(true)
+		r.route(Reply{ReplyXoff, []byte{c}})
+	case c == idHeader:
+		r.partial, r.partialKind = []byte{c}, ReplyID
+	case escpos.IsStatusReply(c):
+		r.route(Reply{ReplyStatus, []byte{c}})
+	case c&0b1001_0011 == 0b0001_0000:
+		r.partial, r.partialKind = []byte{c}, ReplyAutoStatus
+	case c&0b0001_0000 == 0:
+		r.route(Reply{ReplyID, []byte{c}})
+	default:
+		r.route(Reply{ReplyUnknown, []byte{c}})
+	}
+}
+
+func (r *replies) setPaused(paused bool) {
+	if r.paused == paused {
+		return
+	}
+	r.paused = paused
+	close(r.flowChanged)
+	r.flowChanged = make(chan struct{})
+}
+
+// route hands reply to the oldest request waiting for it, otherwise to every
+// subscriber that has room for it.  r.mu must be held.
+func (r *replies) route(reply Reply) {
+	if waiting := r.waiting[reply.Kind]; len(waiting) > 0 {
+		waiting[0] <- replyResult{data: reply.Data}
+		r.waiting[reply.Kind] = waiting[1:]
+		return
+	}
+
+	for ch := range r.subscribers {
+		select {
+		case ch <- reply:
+		default:
+		}
+	}
+}
+
+// cancel stops waiting on ch, returning false if it was already answered.
+// r.mu must be held.
+func (r *replies) cancel(kind ReplyKind, ch chan replyResult) bool {
+	waiting := r.waiting[kind]
+	for i := range waiting {
+		if waiting[i] == ch {
+			r.waiting[kind] = append(waiting[:i:i], waiting[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// request sends a request encoded by escpos and waits for the reply to it,
+// until the context of the printer is done
+func (p Printer) request(data []byte, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	r := p.replies
+
+	kind := ReplyStatus
+	if data[0] == GS {
+		kind = ReplyID
+	}
+
+	ch := make(chan replyResult, 1)
+
+	r.sendMu.Lock()
+	r.mu.Lock()
+	r.waiting[kind] = append(r.waiting[kind], ch)
+	r.mu.Unlock()
+
+	_, err = p.Write(data)
+	if err == nil {
+		err = p.Flush()
+	}
+	if err != nil {
+		r.mu.Lock()
+		r.cancel(kind, ch)
+		r.release()
+		r.mu.Unlock()
+		r.sendMu.Unlock()
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.start(p.dst)
+	r.mu.Unlock()
+	r.sendMu.Unlock()
+
+	var done <-chan struct{}
+	if p.ctx != nil {
+		done = p.ctx.Done()
+	}
+
+	select {
+	case result := <-ch:
+		if result.err != nil {
+			return nil, fmt.Errorf("could not read from printer: %w", checkTimeout(result.err))
+		}
+		return result.data, nil
+	case <-done:
+		r.mu.Lock()
+		waiting := r.cancel(kind, ch)
+		r.release()
+		r.mu.Unlock()
+
+		if !waiting {
+			// Answered while giving up, so use it
+			result := <-ch
+			if result.err == nil {
+				return result.data, nil
+			}
+		}
+		return nil, fmt.Errorf("could not read from printer: %w", p.contextErr())
+	}
+}
+
+// Subscribe returns a channel of the replies that no request is waiting
+// for, like Automatic Status Back blocks or late answers to requests that
+// timed out.  Replies are dropped if the channel falls behind.
+//
+// Replies are read in the background until unsubscribe is called.  The
+// channel is closed when reading fails, like when the printer is closed, so
+// transports that return io.EOF when there's nothing to read close it
+// straight away.  On transports without read deadlines the read in progress
+// after unsubscribing lasts until the printer sends something, and Read
+// fails with ErrReadPending until then.
+func (p Printer) Subscribe() (replies <-chan Reply, unsubscribe func()) {
+	r := p.replies
+	ch := make(chan Reply, subscriberSize)
+
+	r.mu.Lock()
+	r.subscribers[ch] = true
+	r.start(p.dst)
+	r.mu.Unlock()
+
+	return ch, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.subscribers[ch] {
+			delete(r.subscribers, ch)
+			close(ch)
+			r.release()
+		}
+	}
+}
+
+// flowError returns why the reader stopped if it wasn't because there was
+// nothing left to read.  r.mu must be held.
+func (r *replies) flowError() error {
+	if r.err == nil || errors.Is(r.err, io.EOF) || errors.Is(r.err, os.ErrDeadlineExceeded) {
+		return nil
+	}
+	return r.err
+}
@@ -0,0 +1,262 @@
+package hoin_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joeyak/hoin-printer"
+	"github.com/joeyak/hoin-printer/emulator"
+)
+
+// autoStatus is an Automatic Status Back block with the paper near its end
+var autoStatus = []byte{0x10, 0x00, 0x0C, 0x00}
+
+// chattyPrinter answers status and ID requests like a printer with
+// Automatic Status Back enabled, sending a block before every reply.  When
+// delay is set the replies are sent late.
+func chattyPrinter(t *testing.T, status emulator.Status, delay time.Duration) net.Conn {
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	go func() {
+		var written []byte
+		b := make([]byte, 64)
+		for {
+			n, err := server.Read(b)
+			if err != nil {
+				return
+			}
+
+			for _, c := range b[:n] {
+				written = append(written, c)
+				if len(written) < 3 {
+					continue
+				}
+
+				var reply []byte
+				switch prefix := written[len(written)-3 : len(written)-1]; {
+				case bytes.Equal(prefix, []byte{hoin.DLE, 0x04}):
+					reply = []byte{status.Reply(c)}
+				case bytes.Equal(prefix, []byte{hoin.GS, 'I'}):
+					reply = emulator.DefaultID.Reply(c)
+				default:
+					continue
+				}
+
+				time.Sleep(delay)
+				_, err = server.Write(append(append([]byte{}, autoStatus...), reply...))
+				if err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	return client
+}
+
+func TestRepliesConcurrent(t *testing.T) {
+	printer := hoin.NewPrinter(chattyPrinter(t, emulator.Status{CutterError: true, PaperOut: true}, 0))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			status, err := printer.TransmitErrorStatus()
+			if err != nil {
+				t.Error(err)
+			} else if !status.AutoCutter {
+				t.Errorf("expected an auto cutter error but got %+v", status)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			status, err := printer.TransmitPaperSensorStatus()
+			if err != nil {
+				t.Error(err)
+			} else if !status.RollEnd {
+				t.Errorf("expected the paper to be out but got %+v", status)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			id, err := printer.TransmitPrinterID(hoin.IDManufacturer)
+			if err != nil {
+				t.Error(err)
+			} else if string(id) != "HOIN" {
+				t.Errorf("expected the manufacturer to be HOIN but got %q", id)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRepliesSubscribe(t *testing.T) {
+	printer := hoin.NewPrinter(chattyPrinter(t, emulator.Status{DrawerOpen: true}, 100*time.Millisecond))
+
+	replies, unsubscribe := printer.Subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := printer.WithContext(ctx).TransmitPrinterStatus()
+	if !errors.Is(err, hoin.ErrTimeout) {
+		t.Fatalf("expected %q to be a timeout", err)
+	}
+
+	// The block and the late answer go to the subscriber instead of being
+	// taken as the answer to the next request
+	want := []hoin.Reply{
+		{Kind: hoin.ReplyAutoStatus, Data: autoStatus},
+		{Kind: hoin.ReplyStatus, Data: []byte{0x12 | 0x04}},
+	}
+	for _, w := range want {
+		select {
+		case got := <-replies:
+			if got.Kind != w.Kind || !bytes.Equal(got.Data, w.Data) {
+				t.Errorf("expected a %s reply of % X but got a %s reply of % X", w.Kind, w.Data, got.Kind, got.Data)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("never got a %s reply", w.Kind)
+		}
+	}
+
+	status, err := printer.TransmitPrinterStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.DrawerOpen {
+		t.Errorf("expected the drawer to be open but got %+v", status)
+	}
+}
+
+func TestRepliesSubscribeClose(t *testing.T) {
+	printer := hoin.NewPrinter(chattyPrinter(t, emulator.Status{}, 0))
+
+	replies, unsubscribe := printer.Subscribe()
+	defer unsubscribe()
+
+	printer.Close()
+
+	select {
+	case _, ok := <-replies:
+		if ok {
+			t.Error("expected the channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel was not closed with the printer")
+	}
+}
+
+func TestRepliesStopWhenIdle(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	// The printer takes the request but never answers it
+	go func() {
+		b := make([]byte, 3)
+		server.Read(b)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	printer := hoin.NewPrinter(client)
+	_, err := printer.WithContext(ctx).TransmitErrorStatus()
+	if !errors.Is(err, hoin.ErrTimeout) {
+		t.Fatalf("expected %q to be a timeout", err)
+	}
+
+	// Nothing is waiting for replies any more, so the background reader
+	// doesn't take the next byte from a direct read
+	go server.Write([]byte("x"))
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	b := make([]byte, 1)
+	n, err := printer.WithContext(ctx).Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b[:n]) != "x" {
+		t.Errorf("expected to read %q but got %q", "x", b[:n])
+	}
+}
+
+func TestRepliesReadPending(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		b := make([]byte, 3)
+		server.Read(b)
+	}()
+
+	// Hiding SetReadDeadline leaves the background reader blocked after the
+	// request gives up
+	printer := hoin.NewPrinter(struct{ io.ReadWriter }{client})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := printer.WithContext(ctx).TransmitErrorStatus()
+	if !errors.Is(err, hoin.ErrTimeout) {
+		t.Fatalf("expected %q to be a timeout", err)
+	}
+
+	_, err = printer.Read(make([]byte, 1))
+	if !errors.Is(err, hoin.ErrReadPending) {
+		t.Fatalf("expected %q to be a pending read", err)
+	}
+
+	// The reader stops once it gets a byte, then reads go to the transport
+	_, err = server.Write([]byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Write([]byte("y"))
+
+	b := make([]byte, 1)
+	deadline := time.Now().Add(time.Second)
+	for {
+		var n int
+		n, err = printer.Read(b)
+		if errors.Is(err, hoin.ErrReadPending) && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b[:n]) != "y" {
+			t.Errorf("expected to read %q but got %q", "y", b[:n])
+		}
+		break
+	}
+}
+
+func TestRepliesReadWhileSubscribed(t *testing.T) {
+	printer := hoin.NewPrinter(chattyPrinter(t, emulator.Status{}, 0))
+
+	_, unsubscribe := printer.Subscribe()
+	defer unsubscribe()
+
+	_, err := printer.Read(make([]byte, 1))
+	if err == nil {
+		t.Error("expected reading to fail while replies are read in the background")
+	}
+}
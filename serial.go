@@ -0,0 +1,108 @@
+package hoin
+
+import (
+	"fmt"
+	"os"
+)
+
+type Parity int
+
+const (
+	ParityNone Parity = iota
+	ParityOdd
+	ParityEven
+)
+
+// Handshake is the flow control done by the serial port itself
+type Handshake int
+
+const (
+	HandshakeNone Handshake = iota
+	// HandshakeRTSCTS uses the RTS and CTS lines
+	HandshakeRTSCTS
+	// HandshakeXonXoff pauses sending when the printer sends XOFF until it
+	// sends XON.  The XON and XOFF bytes are never seen when reading.
+	HandshakeXonXoff
+)
+
+// SerialOptions configures a serial port.  The zero value is 9600 baud 8N1
+// without a handshake.
+type SerialOptions struct {
+	// Baud is the bits per second.  9600 is used when Baud is 0.
+	Baud int
+	// DataBits is between 5 and 8.  8 is used when DataBits is 0.
+	DataBits int
+	Parity   Parity
+	// StopBits is 1 or 2.  1 is used when StopBits is 0.
+	StopBits  int
+	Handshake Handshake
+}
+
+func (o SerialOptions) withDefaults() (SerialOptions, error) {
+	if o.Baud == 0 {
+		o.Baud = 9600
+	}
+	if o.DataBits == 0 {
+		o.DataBits = 8
+	}
+	if o.StopBits == 0 {
+		o.StopBits = 1
+	}
+
+	err := checkRange(o.DataBits, 5, 8, "data bits")
+	if err != nil {
+		return o, err
+	}
+
+	err = checkRange(o.StopBits, 1, 2, "stop bits")
+	if err != nil {
+		return o, err
+	}
+
+	err = checkEnum(o.Parity, ParityNone, ParityOdd, ParityEven)
+	if err != nil {
+		return o, err
+	}
+
+	err = checkEnum(o.Handshake, HandshakeNone, HandshakeRTSCTS, HandshakeXonXoff)
+	if err != nil {
+		return o, err
+	}
+
+	return o, nil
+}
+
+// OpenSerial opens a tty like /dev/ttyUSB0 in raw mode with the given
+// settings
+//
+// The returned file supports read deadlines.
+func OpenSerial(path string, opts SerialOptions) (*os.File, error) {
+	errMsg := "could not open serial port: %w"
+
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+
+	file, err := openSerial(path, opts)
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+	return file, nil
+}
+
+// NewSerialPrinter opens a printer on a serial port
+//
+// When the port does a handshake it already keeps the printer from
+// overflowing, so images and long text default to NoFlowControl.
+func NewSerialPrinter(path string, serial SerialOptions, opts ...Option) (Printer, error) {
+	file, err := OpenSerial(path, serial)
+	if err != nil {
+		return Printer{}, err
+	}
+
+	if serial.Handshake != HandshakeNone {
+		opts = append([]Option{WithFlowControl(NoFlowControl())}, opts...)
+	}
+	return NewPrinter(file, opts...), nil
+}
@@ -0,0 +1,109 @@
+//go:build linux
+
+package hoin
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+var baudRates = map[int]uint32{
+	1200:   unix.B1200,
+	2400:   unix.B2400,
+	4800:   unix.B4800,
+	9600:   unix.B9600,
+	19200:  unix.B19200,
+	38400:  unix.B38400,
+	57600:  unix.B57600,
+	115200: unix.B115200,
+	230400: unix.B230400,
+}
+
+var dataBits = map[int]uint32{
+	5: unix.CS5,
+	6: unix.CS6,
+	7: unix.CS7,
+	8: unix.CS8,
+}
+
+// termios sets t to raw mode with the settings in opts
+func termios(t *unix.Termios, opts SerialOptions) error {
+	rate, ok := baudRates[opts.Baud]
+	if !ok {
+		return fmt.Errorf("unsupported baud rate %d", opts.Baud)
+	}
+
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL |
+		unix.IXON | unix.IXOFF | unix.IXANY | unix.INPCK
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CBAUD | unix.CSIZE | unix.PARENB | unix.PARODD | unix.CSTOPB | unix.CRTSCTS
+	t.Cflag |= unix.CREAD | unix.CLOCAL | rate | dataBits[opts.DataBits]
+	t.Ispeed, t.Ospeed = rate, rate
+
+	switch opts.Parity {
+	case ParityOdd:
+		t.Cflag |= unix.PARENB | unix.PARODD
+		t.Iflag |= unix.INPCK
+	case ParityEven:
+		t.Cflag |= unix.PARENB
+		t.Iflag |= unix.INPCK
+	}
+
+	if opts.StopBits == 2 {
+		t.Cflag |= unix.CSTOPB
+	}
+
+	switch opts.Handshake {
+	case HandshakeRTSCTS:
+		t.Cflag |= unix.CRTSCTS
+	case HandshakeXonXoff:
+		t.Iflag |= unix.IXON | unix.IXOFF
+	}
+
+	// Reads return as soon as a byte is available
+	t.Cc[unix.VMIN] = 1
+	t.Cc[unix.VTIME] = 0
+	return nil
+}
+
+func openSerial(path string, opts SerialOptions) (*os.File, error) {
+	// O_NONBLOCK lets the runtime poller handle reads so deadlines work
+	file, err := os.OpenFile(path, os.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := file.SyscallConn()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	var termiosErr error
+	err = conn.Control(func(fd uintptr) {
+		var t *unix.Termios
+		t, termiosErr = unix.IoctlGetTermios(int(fd), unix.TCGETS)
+		if termiosErr != nil {
+			return
+		}
+
+		termiosErr = termios(t, opts)
+		if termiosErr != nil {
+			return
+		}
+
+		termiosErr = unix.IoctlSetTermios(int(fd), unix.TCSETS, t)
+	})
+	if err == nil {
+		err = termiosErr
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
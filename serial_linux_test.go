@@ -0,0 +1,184 @@
+//go:build linux
+
+package hoin_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/joeyak/hoin-printer"
+	"golang.org/x/sys/unix"
+)
+
+// openPty opens a pseudo-terminal pair.  The master stands in for the
+// printer and the returned path is the tty to open as the serial port.
+func openPty(t *testing.T) (*os.File, string) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("pseudo-terminals are not available: %s", err)
+	}
+	t.Cleanup(func() { master.Close() })
+
+	conn, err := master.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	var ptyErr error
+	err = conn.Control(func(fd uintptr) {
+		ptyErr = unix.IoctlSetPointerInt(int(fd), unix.TIOCSPTLCK, 0)
+		if ptyErr == nil {
+			n, ptyErr = unix.IoctlGetInt(int(fd), unix.TIOCGPTN)
+		}
+	})
+	if err == nil {
+		err = ptyErr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return master, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+func getTermios(t *testing.T, file *os.File) *unix.Termios {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var termios *unix.Termios
+	var termiosErr error
+	err = conn.Control(func(fd uintptr) {
+		termios, termiosErr = unix.IoctlGetTermios(int(fd), unix.TCGETS)
+	})
+	if err == nil {
+		err = termiosErr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return termios
+}
+
+func TestSerialOptions(t *testing.T) {
+	_, path := openPty(t)
+
+	// Linux ptys always use 8 data bits without parity whatever they are
+	// set to, so only the rest can be checked
+	port, err := hoin.OpenSerial(path, hoin.SerialOptions{
+		Baud:      19200,
+		StopBits:  2,
+		Handshake: hoin.HandshakeRTSCTS,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer port.Close()
+
+	termios := getTermios(t, port)
+	checks := []struct {
+		name string
+		ok   bool
+	}{
+		{"19200 baud", termios.Cflag&unix.CBAUD == unix.B19200},
+		{"2 stop bits", termios.Cflag&unix.CSTOPB != 0},
+		{"RTS/CTS", termios.Cflag&unix.CRTSCTS != 0},
+		{"no XON/XOFF", termios.Iflag&(unix.IXON|unix.IXOFF) == 0},
+		{"raw output", termios.Oflag&unix.OPOST == 0},
+		{"raw input", termios.Lflag&(unix.ICANON|unix.ECHO) == 0},
+	}
+	for _, check := range checks {
+		if !check.ok {
+			t.Errorf("serial port was not set to %s", check.name)
+		}
+	}
+}
+
+func TestSerialPrinter(t *testing.T) {
+	master, path := openPty(t)
+
+	printer, err := hoin.NewSerialPrinter(path, hoin.SerialOptions{Baud: 19200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer printer.Close()
+
+	err = printer.Println("Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	master.SetReadDeadline(time.Now().Add(time.Second))
+	got := make([]byte, 6)
+	_, err = io.ReadFull(master, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "Hello\n" {
+		t.Errorf("expected the printer to get %q but got %q", "Hello\n", got)
+	}
+
+	// Reply before the request is read so the status doesn't wait on it
+	_, err = master.Write([]byte{0x12 | 0x08})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := printer.TransmitErrorStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.AutoCutter {
+		t.Errorf("expected an auto cutter error but got %+v", status)
+	}
+}
+
+func TestOpenSerial(t *testing.T) {
+	_, path := openPty(t)
+
+	printer, err := hoin.Open("serial://" + path + "?baud=38400&stop_bits=2&handshake=rtscts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer printer.Close()
+
+	port, err := os.OpenFile(path, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer port.Close()
+
+	termios := getTermios(t, port)
+	if termios.Cflag&unix.CBAUD != unix.B38400 || termios.Cflag&unix.CSTOPB == 0 || termios.Cflag&unix.CRTSCTS == 0 {
+		t.Errorf("serial port was not set up from the query, cflag is %#o", termios.Cflag)
+	}
+}
+
+func TestSerialInvalidOptions(t *testing.T) {
+	_, path := openPty(t)
+
+	tests := []struct {
+		name string
+		opts hoin.SerialOptions
+	}{
+		{"Baud", hoin.SerialOptions{Baud: 12345}},
+		{"DataBits", hoin.SerialOptions{DataBits: 9}},
+		{"StopBits", hoin.SerialOptions{StopBits: 3}},
+		{"Parity", hoin.SerialOptions{Parity: 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port, err := hoin.OpenSerial(path, tt.opts)
+			if err == nil {
+				port.Close()
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
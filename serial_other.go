@@ -0,0 +1,12 @@
+//go:build !linux
+
+package hoin
+
+import (
+	"errors"
+	"os"
+)
+
+func openSerial(path string, opts SerialOptions) (*os.File, error) {
+	return nil, errors.New("serial ports are only supported on linux")
+}
@@ -0,0 +1,297 @@
+// Package session records the bytes exchanged with a printer to a portable
+// capture file and replays them later.
+//
+// A capture is JSON lines.  The first line is a Header and every line after
+// it is an Event.
+package session
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/joeyak/hoin-printer"
+)
+
+// Version of the capture format
+const Version = 1
+
+// Direction of an event from the point of view of the application
+type Direction string
+
+const (
+	Write Direction = "write"
+	Read  Direction = "read"
+)
+
+// Header is the first line of a capture
+type Header struct {
+	Version int       `json:"version"`
+	Start   time.Time `json:"start"`
+}
+
+// Event is a single write to or read from the printer
+type Event struct {
+	// Offset is the time since the start of the capture
+	Offset    time.Duration
+	Direction Direction
+	Data      []byte
+}
+
+type eventJSON struct {
+	Offset    time.Duration `json:"offset"`
+	Direction Direction     `json:"direction"`
+	Data      string        `json:"data"`
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal(eventJSON{e.Offset, e.Direction, hex.EncodeToString(e.Data)})
+}
+
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var j eventJSON
+	err := json.Unmarshal(b, &j)
+	if err != nil {
+		return err
+	}
+
+	data, err := hex.DecodeString(j.Data)
+	if err != nil {
+		return fmt.Errorf("could not decode event data: %w", err)
+	}
+
+	*e = Event{Offset: j.Offset, Direction: j.Direction, Data: data}
+	return nil
+}
+
+// Recorder is an io.ReadWriter that records everything passing through it
+type Recorder struct {
+	rw    io.ReadWriter
+	start time.Time
+
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewRecorder wraps rw and writes a capture of everything written to and
+// read from it to capture
+func NewRecorder(rw io.ReadWriter, capture io.Writer) (*Recorder, error) {
+	r := &Recorder{
+		rw:    rw,
+		start: time.Now(),
+		enc:   json.NewEncoder(capture),
+	}
+
+	err := r.enc.Encode(Header{Version: Version, Start: r.start})
+	if err != nil {
+		return nil, fmt.Errorf("could not write capture header: %w", err)
+	}
+	return r, nil
+}
+
+func (r *Recorder) record(dir Direction, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+
+	r.err = r.enc.Encode(Event{
+		Offset:    time.Since(r.start),
+		Direction: dir,
+		Data:      append([]byte{}, data...),
+	})
+}
+
+// Write writes b to the wrapped ReadWriter and records the bytes that were
+// written
+func (r *Recorder) Write(b []byte) (int, error) {
+	n, err := r.rw.Write(b)
+	r.record(Write, b[:n])
+	return n, err
+}
+
+// Read reads from the wrapped ReadWriter and records the bytes that were
+// read
+func (r *Recorder) Read(b []byte) (int, error) {
+	n, err := r.rw.Read(b)
+	r.record(Read, b[:n])
+	return n, err
+}
+
+// SetReadDeadline sets the read deadline of the wrapped ReadWriter,
+// returning os.ErrNoDeadline if it doesn't have one
+func (r *Recorder) SetReadDeadline(t time.Time) error {
+	deadliner, ok := r.rw.(interface{ SetReadDeadline(time.Time) error })
+	if !ok {
+		return os.ErrNoDeadline
+	}
+	return deadliner.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline of the wrapped ReadWriter,
+// returning os.ErrNoDeadline if it doesn't have one
+func (r *Recorder) SetWriteDeadline(t time.Time) error {
+	deadliner, ok := r.rw.(interface{ SetWriteDeadline(time.Time) error })
+	if !ok {
+		return os.ErrNoDeadline
+	}
+	return deadliner.SetWriteDeadline(t)
+}
+
+// Err returns the first error that happened while writing the capture
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close closes the wrapped ReadWriter if it is an io.Closer.  The capture is
+// left open.
+func (r *Recorder) Close() error {
+	closer, ok := r.rw.(io.Closer)
+	if !ok {
+		return nil
+	}
+	return closer.Close()
+}
+
+// Capture is a decoded capture file
+type Capture struct {
+	Header Header
+	Events []Event
+}
+
+// ReadCapture decodes a capture written by a Recorder
+func ReadCapture(r io.Reader) (Capture, error) {
+	errMsg := "could not read capture: %w"
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64*1024*1024)
+
+	var c Capture
+	if !scanner.Scan() {
+		err := scanner.Err()
+		if err == nil {
+			err = errors.New("capture is empty")
+		}
+		return c, fmt.Errorf(errMsg, err)
+	}
+
+	err := json.Unmarshal(scanner.Bytes(), &c.Header)
+	if err != nil {
+		return c, fmt.Errorf(errMsg, err)
+	}
+
+	if c.Header.Version != Version {
+		return c, fmt.Errorf(errMsg, fmt.Errorf("unsupported version %d", c.Header.Version))
+	}
+
+	for line := 2; scanner.Scan(); line++ {
+		var e Event
+		err := json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			return c, fmt.Errorf(errMsg, fmt.Errorf("line %d: %w", line, err))
+		}
+		c.Events = append(c.Events, e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return c, fmt.Errorf(errMsg, err)
+	}
+	return c, nil
+}
+
+// Clock is the time Replay waits between events with
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time        { return time.Now() }
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// ReplayOptions controls the timing of Replay
+type ReplayOptions struct {
+	// Speed scales the time between events.  1 keeps the original timing, 2
+	// replays twice as fast and 0 sends everything without waiting.
+	Speed float64
+	// MaxDelay caps the time between two events after scaling when it is
+	// greater than 0
+	MaxDelay time.Duration
+	// Clock waits between events.  The system clock is used when it is nil.
+	Clock Clock
+}
+
+// Replay sends every write in the capture to dst
+//
+// When dst is also an io.Reader, each recorded read waits for the same
+// number of bytes from dst so the printer can keep pace the way it did when
+// the capture was recorded.  The bytes read are not compared to the
+// capture.  Printers that can't be read from, which fail with
+// hoin.ErrWriteOnly, are sent the writes without waiting.
+func Replay(dst io.Writer, c Capture, opts ReplayOptions) error {
+	errMsg := "could not replay capture: %w"
+
+	clock := opts.Clock
+	if clock == nil {
+		clock = realClock{}
+	}
+
+	reader, canRead := dst.(io.Reader)
+	start := clock.Now()
+	var elapsed time.Duration
+	var previous time.Duration
+
+	for i, e := range c.Events {
+		if opts.Speed > 0 {
+			gap := time.Duration(float64(e.Offset-previous) / opts.Speed)
+			if opts.MaxDelay > 0 && gap > opts.MaxDelay {
+				gap = opts.MaxDelay
+			}
+			elapsed += gap
+			if wait := start.Add(elapsed).Sub(clock.Now()); wait > 0 {
+				clock.Sleep(wait)
+			}
+		}
+		previous = e.Offset
+
+		switch e.Direction {
+		case Write:
+			_, err := dst.Write(e.Data)
+			if err != nil {
+				return fmt.Errorf(errMsg, fmt.Errorf("event %d: %w", i, err))
+			}
+		case Read:
+			if !canRead {
+				continue
+			}
+			_, err := io.ReadFull(reader, make([]byte, len(e.Data)))
+			if errors.Is(err, hoin.ErrWriteOnly) {
+				canRead = false
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf(errMsg, fmt.Errorf("event %d: %w", i, err))
+			}
+		default:
+			return fmt.Errorf(errMsg, fmt.Errorf("event %d has unknown direction %q", i, e.Direction))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package session_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/joeyak/hoin-printer"
+	"github.com/joeyak/hoin-printer/emulator"
+	"github.com/joeyak/hoin-printer/session"
+)
+
+// fakeClock records sleeps and moves time forward by them instead of
+// waiting
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+}
+
+func TestRecordReplay(t *testing.T) {
+	var capture bytes.Buffer
+
+	original := emulator.NewDevice()
+	recorder, err := session.NewRecorder(original, &capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	printer := hoin.NewPrinter(recorder)
+	printer.Initialize()
+	printer.Println("Hello")
+	time.Sleep(20 * time.Millisecond)
+	printer.TransmitErrorStatus()
+	printer.Cut()
+
+	if err := recorder.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := session.ReadCapture(&capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reads int
+	for _, e := range c.Events {
+		if e.Direction == session.Read {
+			reads++
+		}
+	}
+	if reads != 1 {
+		t.Errorf("expected 1 read but got %d", reads)
+	}
+
+	replayed := emulator.NewDevice()
+	clock := &fakeClock{}
+	err = session.Replay(replayed, c, session.ReplayOptions{Speed: 1, MaxDelay: time.Millisecond, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The 20ms pause before the status request is cut down to 1ms
+	for _, d := range clock.sleeps {
+		if d > time.Millisecond {
+			t.Errorf("replay was not compressed and slept for %s", d)
+		}
+	}
+	if len(clock.sleeps) == 0 {
+		t.Error("expected replay to wait between events")
+	}
+
+	if !bytes.Equal(original.Bytes(), replayed.Bytes()) {
+		t.Errorf("replayed %q but recorded %q", replayed.Bytes(), original.Bytes())
+	}
+}
+
+func TestReplayOriginalTiming(t *testing.T) {
+	c := session.Capture{
+		Header: session.Header{Version: session.Version},
+		Events: []session.Event{
+			{Offset: 0, Direction: session.Write, Data: []byte("a")},
+			{Offset: 30 * time.Millisecond, Direction: session.Write, Data: []byte("b")},
+		},
+	}
+
+	var dst bytes.Buffer
+	clock := &fakeClock{}
+	err := session.Replay(&dst, c, session.ReplayOptions{Speed: 1, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(clock.sleeps) != 1 || clock.sleeps[0] != 30*time.Millisecond {
+		t.Errorf("expected one 30ms wait like the capture but got %v", clock.sleeps)
+	}
+	if dst.String() != "ab" {
+		t.Errorf("replayed %q", dst.String())
+	}
+}
+
+func TestReplaySpeed(t *testing.T) {
+	c := session.Capture{
+		Header: session.Header{Version: session.Version},
+		Events: []session.Event{
+			{Offset: 0, Direction: session.Write, Data: []byte("a")},
+			{Offset: 30 * time.Millisecond, Direction: session.Write, Data: []byte("b")},
+			{Offset: 90 * time.Millisecond, Direction: session.Write, Data: []byte("c")},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		opts   session.ReplayOptions
+		sleeps []time.Duration
+	}{
+		{"Double", session.ReplayOptions{Speed: 2}, []time.Duration{15 * time.Millisecond, 30 * time.Millisecond}},
+		{"MaxDelay", session.ReplayOptions{Speed: 1, MaxDelay: 40 * time.Millisecond}, []time.Duration{30 * time.Millisecond, 40 * time.Millisecond}},
+		{"NoWaiting", session.ReplayOptions{Speed: 0}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{}
+			tt.opts.Clock = clock
+
+			var dst bytes.Buffer
+			err := session.Replay(&dst, c, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(clock.sleeps, tt.sleeps) {
+				t.Errorf("expected waits of %v but got %v", tt.sleeps, clock.sleeps)
+			}
+			if dst.String() != "abc" {
+				t.Errorf("replayed %q", dst.String())
+			}
+		})
+	}
+}
+
+func TestReplayWriteOnly(t *testing.T) {
+	c := session.Capture{
+		Header: session.Header{Version: session.Version},
+		Events: []session.Event{
+			{Direction: session.Write, Data: []byte("a\x10\x04\x03")},
+			{Direction: session.Read, Data: []byte{0x12}},
+			{Direction: session.Write, Data: []byte("b")},
+			{Direction: session.Read, Data: []byte{0x12}},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "out.bin")
+	printer, err := hoin.Open("file://" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = session.Replay(printer, c, session.ReplayOptions{})
+	if err != nil {
+		t.Fatalf("expected reads to be skipped on a write only printer but got %q", err)
+	}
+	err = printer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "a\x10\x04\x03b" {
+		t.Errorf("replayed %q", got)
+	}
+}
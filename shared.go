@@ -0,0 +1,188 @@
+package hoin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrClosed is returned by a SharedPrinter after it is closed
+var ErrClosed = errors.New("printer is closed")
+
+// SharedPrinter lets many goroutines use one printer without their receipts
+// getting mixed up
+//
+// Each job has the printer to itself until it returns.  Jobs run in the
+// order they were queued, and the status methods wait their turn like a
+// job so a status round trip never lands in the middle of a receipt.
+type SharedPrinter struct {
+	printer Printer
+
+	mu     sync.Mutex
+	busy   bool
+	queue  []chan struct{}
+	closed bool
+}
+
+// NewSharedPrinter shares p between goroutines.  p shouldn't be used
+// directly after this.
+func NewSharedPrinter(p Printer) *SharedPrinter {
+	return &SharedPrinter{printer: p}
+}
+
+// acquire waits for the turn of the caller
+func (s *SharedPrinter) acquire(ctx context.Context) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	if !s.busy {
+		s.busy = true
+		s.mu.Unlock()
+		return nil
+	}
+
+	turn := make(chan struct{})
+	s.queue = append(s.queue, turn)
+	s.mu.Unlock()
+
+	select {
+	case <-turn:
+	case <-ctx.Done():
+		s.mu.Lock()
+		for i, t := range s.queue {
+			if t == turn {
+				s.queue = append(s.queue[:i:i], s.queue[i+1:]...)
+				s.mu.Unlock()
+				return checkTimeout(ctx.Err())
+			}
+		}
+		s.mu.Unlock()
+
+		// The turn came while giving up, so pass it on
+		s.release()
+		return checkTimeout(ctx.Err())
+	}
+
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		s.release()
+		return ErrClosed
+	}
+	return nil
+}
+
+// release hands the printer to the next in the queue
+func (s *SharedPrinter) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.queue) == 0 {
+		s.busy = false
+		return
+	}
+	close(s.queue[0])
+	s.queue = s.queue[1:]
+}
+
+// Job waits for the printer and runs job with it.  Anything job leaves in
+// the buffer is flushed before the next job starts.
+func (s *SharedPrinter) Job(job func(Printer) error) error {
+	return s.JobContext(context.Background(), job)
+}
+
+// JobContext is Job where ctx limits both the wait for the printer and the
+// job itself
+func (s *SharedPrinter) JobContext(ctx context.Context, job func(Printer) error) error {
+	errMsg := "could not run print job: %w"
+
+	err := s.acquire(ctx)
+	if err != nil {
+		return fmt.Errorf(errMsg, err)
+	}
+	defer s.release()
+
+	p := s.printer.WithContext(ctx)
+	err = job(p)
+	flushErr := p.Flush()
+	if err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		return fmt.Errorf(errMsg, err)
+	}
+	return nil
+}
+
+// status runs a status request in its turn, with ctx limiting both the
+// wait for the printer and the request
+func status[T any](ctx context.Context, s *SharedPrinter, transmit func(Printer) (T, error)) (T, error) {
+	var result T
+	err := s.acquire(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer s.release()
+
+	return transmit(s.printer.WithContext(ctx))
+}
+
+func (s *SharedPrinter) TransmitPrinterStatus() (PrinterStatus, error) {
+	return s.TransmitPrinterStatusContext(context.Background())
+}
+
+// TransmitPrinterStatusContext is TransmitPrinterStatus where ctx limits both
+// the wait for the printer and the request
+func (s *SharedPrinter) TransmitPrinterStatusContext(ctx context.Context) (PrinterStatus, error) {
+	return status(ctx, s, Printer.TransmitPrinterStatus)
+}
+
+func (s *SharedPrinter) TransmitOfflineStatus() (OfflineStatus, error) {
+	return s.TransmitOfflineStatusContext(context.Background())
+}
+
+// TransmitOfflineStatusContext is TransmitOfflineStatus where ctx limits both
+// the wait for the printer and the request
+func (s *SharedPrinter) TransmitOfflineStatusContext(ctx context.Context) (OfflineStatus, error) {
+	return status(ctx, s, Printer.TransmitOfflineStatus)
+}
+
+func (s *SharedPrinter) TransmitErrorStatus() (ErrorStatus, error) {
+	return s.TransmitErrorStatusContext(context.Background())
+}
+
+// TransmitErrorStatusContext is TransmitErrorStatus where ctx limits both the
+// wait for the printer and the request
+func (s *SharedPrinter) TransmitErrorStatusContext(ctx context.Context) (ErrorStatus, error) {
+	return status(ctx, s, Printer.TransmitErrorStatus)
+}
+
+func (s *SharedPrinter) TransmitPaperSensorStatus() (PaperSensorStatus, error) {
+	return s.TransmitPaperSensorStatusContext(context.Background())
+}
+
+// TransmitPaperSensorStatusContext is TransmitPaperSensorStatus where ctx
+// limits both the wait for the printer and the request
+func (s *SharedPrinter) TransmitPaperSensorStatusContext(ctx context.Context) (PaperSensorStatus, error) {
+	return status(ctx, s, Printer.TransmitPaperSensorStatus)
+}
+
+// Close waits for the jobs already queued and then closes the printer.
+// Jobs queued after Close fail with ErrClosed.
+func (s *SharedPrinter) Close() error {
+	err := s.acquire(context.Background())
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	defer s.release()
+
+	return s.printer.Close()
+}
@@ -0,0 +1,192 @@
+package hoin_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joeyak/hoin-printer"
+	"github.com/joeyak/hoin-printer/emulator"
+)
+
+func TestSharedPrinterJobs(t *testing.T) {
+	device := emulator.NewDevice()
+	shared := hoin.NewSharedPrinter(hoin.NewPrinter(device))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := shared.Job(func(p hoin.Printer) error {
+				for line := 0; line < 5; line++ {
+					err := p.Println(i)
+					if err != nil {
+						return err
+					}
+					time.Sleep(time.Millisecond)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	// Status requests wait for the jobs around them
+	for i := 0; i < 5; i++ {
+		_, err := shared.TransmitErrorStatus()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	// Every receipt is five lines together, with any status requests
+	// between them
+	for _, before := range strings.Split(string(device.Bytes()), "\x10\x04\x03") {
+		if strings.Count(before, "\n")%5 != 0 {
+			t.Fatalf("status request was sent in the middle of a receipt:\n%q", device.Bytes())
+		}
+	}
+	output := strings.ReplaceAll(string(device.Bytes()), "\x10\x04\x03", "")
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	if len(lines) != 50 {
+		t.Fatalf("expected 50 lines but got %d", len(lines))
+	}
+	for i := 0; i < len(lines); i += 5 {
+		for _, line := range lines[i : i+5] {
+			if line != lines[i] {
+				t.Fatalf("receipts were mixed up:\n%q", lines)
+			}
+		}
+	}
+}
+
+func TestSharedPrinterOrder(t *testing.T) {
+	shared := hoin.NewSharedPrinter(hoin.NewPrinter(emulator.NewDevice()))
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	go shared.Job(func(p hoin.Printer) error {
+		close(started)
+		<-finish
+		return nil
+	})
+	<-started
+
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		i := i
+		wg.Add(1)
+		go shared.Job(func(p hoin.Printer) error {
+			defer wg.Done()
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			return nil
+		})
+
+		// Give each job time to join the queue before the next
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(finish)
+	wg.Wait()
+
+	if fmt.Sprint(order) != "[0 1 2 3 4]" {
+		t.Errorf("expected jobs to run in the order they were queued but got %v", order)
+	}
+}
+
+func TestSharedPrinterCancel(t *testing.T) {
+	shared := hoin.NewSharedPrinter(hoin.NewPrinter(emulator.NewDevice()))
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	go shared.Job(func(p hoin.Printer) error {
+		close(started)
+		<-finish
+		return nil
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := shared.JobContext(ctx, func(p hoin.Printer) error {
+		t.Error("cancelled job was run")
+		return nil
+	})
+	if !errors.Is(err, hoin.ErrTimeout) {
+		t.Errorf("expected %q to be a timeout", err)
+	}
+
+	// The queue keeps going after a job gives up
+	close(finish)
+	err = shared.Job(func(p hoin.Printer) error { return p.Initialize() })
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSharedPrinterStatusContext(t *testing.T) {
+	shared := hoin.NewSharedPrinter(hoin.NewPrinter(emulator.NewDevice()))
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	go shared.Job(func(p hoin.Printer) error {
+		close(started)
+		<-finish
+		return nil
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := shared.TransmitErrorStatusContext(ctx)
+	if !errors.Is(err, hoin.ErrTimeout) {
+		t.Errorf("expected waiting for the job to time out but got %v", err)
+	}
+
+	close(finish)
+	_, err = shared.TransmitPaperSensorStatusContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSharedPrinterClose(t *testing.T) {
+	device := emulator.NewDevice()
+	shared := hoin.NewSharedPrinter(hoin.NewPrinter(device, hoin.WithBuffering(1024)))
+
+	err := shared.Job(func(p hoin.Printer) error { return p.Print("buffered") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(device.Bytes()) != "buffered" {
+		t.Errorf("expected the job to be flushed but got %q", device.Bytes())
+	}
+
+	err = shared.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = shared.Job(func(p hoin.Printer) error { return nil })
+	if !errors.Is(err, hoin.ErrClosed) {
+		t.Errorf("expected %q to be %q", err, hoin.ErrClosed)
+	}
+	_, err = shared.TransmitErrorStatus()
+	if !errors.Is(err, hoin.ErrClosed) {
+		t.Errorf("expected %q to be %q", err, hoin.ErrClosed)
+	}
+}
@@ -0,0 +1,345 @@
+package hoin
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/text/unicode/bidi"
+)
+
+// TextOptions controls how RenderText lays out text
+type TextOptions struct {
+	// Size is the font size in dots, from 1 to DefaultPaperWidth
+	Size float64
+	// Justify aligns each line within Width
+	Justify Justification
+	// Width is the width of the image in dots.  DefaultPaperWidth is used
+	// when Width is 0 and it can't be negative.
+	Width int
+}
+
+// LoadFont reads a TrueType or OpenType font file
+func LoadFont(path string) (*opentype.Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read font: %w", err)
+	}
+
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse font %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// cluster is a base rune followed by any combining marks
+type cluster struct {
+	runes []rune
+	width fixed.Int26_6
+	level int
+}
+
+func splitClusters(s string) []cluster {
+	var clusters []cluster
+	for _, r := range s {
+		if len(clusters) > 0 && unicode.In(r, unicode.Mn, unicode.Me) {
+			last := &clusters[len(clusters)-1]
+			last.runes = append(last.runes, r)
+			continue
+		}
+		clusters = append(clusters, cluster{runes: []rune{r}})
+	}
+	return clusters
+}
+
+func measureClusters(face font.Face, clusters []cluster) fixed.Int26_6 {
+	var width fixed.Int26_6
+	for i := range clusters {
+		adv, _ := face.GlyphAdvance(clusters[i].runes[0])
+		clusters[i].width = adv
+		width += adv
+	}
+	return width
+}
+
+func clusterDirection(c cluster) bidi.Class {
+	props, _ := bidi.LookupRune(c.runes[0])
+	switch props.Class() {
+	case bidi.L:
+		return bidi.L
+	case bidi.R, bidi.AL:
+		return bidi.R
+	case bidi.EN, bidi.AN:
+		return bidi.EN
+	}
+	return bidi.ON
+}
+
+func paragraphDirection(s string) bidi.Class {
+	for _, c := range splitClusters(s) {
+		if dir := clusterDirection(c); dir == bidi.L || dir == bidi.R {
+			return dir
+		}
+	}
+	return bidi.L
+}
+
+// reorderLine assigns simplified bidi embedding levels to the clusters of a
+// line and returns them in visual order
+//
+// Strong characters take their own direction, numbers always read left to
+// right and neutrals take the direction of their surroundings when both
+// sides agree, otherwise the paragraph direction.
+func reorderLine(clusters []cluster, base bidi.Class) []cluster {
+	baseLevel := 0
+	if base == bidi.R {
+		baseLevel = 1
+	}
+
+	dirs := make([]bidi.Class, len(clusters))
+	lastStrong := base
+	for i, c := range clusters {
+		dir := clusterDirection(c)
+		switch dir {
+		case bidi.L, bidi.R:
+			lastStrong = dir
+		case bidi.EN:
+			// Numbers act like the strong text before them for neutrals
+			dir = lastStrong
+		}
+		dirs[i] = dir
+	}
+
+	for i := range clusters {
+		dir := clusterDirection(clusters[i])
+		switch dir {
+		case bidi.L:
+			clusters[i].level = baseLevel
+			if baseLevel == 1 {
+				clusters[i].level = 2
+			}
+		case bidi.R:
+			clusters[i].level = 1
+		case bidi.EN:
+			clusters[i].level = baseLevel
+			if baseLevel == 1 || dirs[i] == bidi.R {
+				clusters[i].level = 2
+			}
+		default:
+			prev, next := base, base
+			for j := i - 1; j >= 0; j-- {
+				if dirs[j] != bidi.ON {
+					prev = dirs[j]
+					break
+				}
+			}
+			for j := i + 1; j < len(dirs); j++ {
+				if dirs[j] != bidi.ON {
+					next = dirs[j]
+					break
+				}
+			}
+
+			clusters[i].level = baseLevel
+			if prev == next && prev == bidi.R {
+				clusters[i].level = 1
+			} else if prev == next && prev == bidi.L && baseLevel == 1 {
+				clusters[i].level = 2
+			}
+		}
+	}
+
+	maxLevel := 0
+	for _, c := range clusters {
+		if c.level > maxLevel {
+			maxLevel = c.level
+		}
+	}
+
+	// Reverse every run at or above each level, from the highest level down
+	// to the lowest odd level
+	for level := maxLevel; level >= 1; level-- {
+		for i := 0; i < len(clusters); {
+			if clusters[i].level < level {
+				i++
+				continue
+			}
+			j := i
+			for j < len(clusters) && clusters[j].level >= level {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				clusters[a], clusters[b] = clusters[b], clusters[a]
+			}
+			i = j
+		}
+	}
+
+	return clusters
+}
+
+// wrapParagraph breaks a paragraph into lines no wider than width
+//
+// Words are split on spaces.  A word wider than a full line is broken
+// between clusters.
+func wrapParagraph(face font.Face, paragraph string, width fixed.Int26_6) [][]cluster {
+	space, _ := face.GlyphAdvance(' ')
+
+	var lines [][]cluster
+	var line []cluster
+	var lineWidth fixed.Int26_6
+
+	for _, word := range strings.Split(paragraph, " ") {
+		clusters := splitClusters(word)
+		wordWidth := measureClusters(face, clusters)
+
+		if len(line) > 0 && lineWidth+space+wordWidth > width {
+			lines = append(lines, line)
+			line, lineWidth = nil, 0
+		}
+
+		if len(line) > 0 {
+			line = append(line, cluster{runes: []rune{' '}, width: space})
+			lineWidth += space
+		}
+
+		for _, c := range clusters {
+			if len(line) > 0 && lineWidth+c.width > width {
+				lines = append(lines, line)
+				line, lineWidth = nil, 0
+			}
+			line = append(line, c)
+			lineWidth += c.width
+		}
+	}
+
+	return append(lines, line)
+}
+
+func drawCluster(d *font.Drawer, c cluster) {
+	start := d.Dot
+	d.DrawString(string(c.runes[0]))
+	end := d.Dot
+
+	for _, mark := range c.runes[1:] {
+		adv, _ := d.Face.GlyphAdvance(mark)
+		if adv == 0 {
+			// Zero width marks are designed to be drawn after their base
+			d.Dot = end
+		} else {
+			// Otherwise center the mark over the base
+			d.Dot.X = start.X + (c.width-adv)/2
+		}
+		d.DrawString(string(mark))
+	}
+
+	d.Dot = end
+}
+
+// RenderText draws text onto a white image using the font f
+//
+// Text is word-wrapped to the image width and each newline starts a new
+// paragraph.  Combining marks are kept with their base character and right to
+// left scripts are laid out in visual order.  Contextual shaping such as
+// Arabic joining is not performed, so those scripts print in their isolated
+// forms.
+func RenderText(text string, f *opentype.Font, opts TextOptions) (*image.Gray, error) {
+	errMsg := "could not render text: %w"
+
+	err := checkEnum(opts.Justify, LeftJustify, CenterJustify, RightJustify)
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+
+	if opts.Size < 1 || opts.Size > DefaultPaperWidth {
+		return nil, fmt.Errorf(errMsg, &RangeError{Field: "size", Min: 1, Max: DefaultPaperWidth, Value: int(opts.Size)})
+	}
+
+	if opts.Width < 0 {
+		return nil, fmt.Errorf(errMsg, fmt.Errorf("width must not be negative but was %d", opts.Width))
+	}
+
+	width := opts.Width
+	if width == 0 {
+		width = DefaultPaperWidth
+	}
+
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    opts.Size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+	defer face.Close()
+
+	type paragraphLine struct {
+		clusters []cluster
+		base     bidi.Class
+	}
+
+	var lines []paragraphLine
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		base := paragraphDirection(paragraph)
+		for _, line := range wrapParagraph(face, paragraph, fixed.I(width)) {
+			lines = append(lines, paragraphLine{clusters: line, base: base})
+		}
+	}
+
+	metrics := face.Metrics()
+	lineHeight := metrics.Height.Ceil()
+	img := image.NewGray(image.Rect(0, 0, width, lineHeight*len(lines)))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.Black,
+		Face: face,
+	}
+
+	for i, line := range lines {
+		clusters := reorderLine(line.clusters, line.base)
+
+		var lineWidth fixed.Int26_6
+		for _, c := range clusters {
+			lineWidth += c.width
+		}
+
+		x := fixed.I(0)
+		switch opts.Justify {
+		case CenterJustify:
+			x = (fixed.I(width) - lineWidth) / 2
+		case RightJustify:
+			x = fixed.I(width) - lineWidth
+		}
+
+		d.Dot = fixed.Point26_6{X: x, Y: fixed.I(i*lineHeight) + metrics.Ascent}
+		for _, c := range clusters {
+			drawCluster(d, c)
+		}
+	}
+
+	return img, nil
+}
+
+// PrintText renders text with RenderText and prints it as a 24-bit image
+func (p Printer) PrintText(text string, f *opentype.Font, opts TextOptions) error {
+	img, err := RenderText(text, f, opts)
+	if err != nil {
+		return fmt.Errorf("could not print text: %w", err)
+	}
+
+	err = p.PrintImage24(img, DoubleDensity)
+	if err != nil {
+		return fmt.Errorf("could not print text: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package hoin
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/text/unicode/bidi"
+)
+
+func clustersString(clusters []cluster) string {
+	var b strings.Builder
+	for _, c := range clusters {
+		b.WriteString(string(c.runes))
+	}
+	return b.String()
+}
+
+func TestReorderLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		base   bidi.Class
+		visual string
+	}{
+		{"LeftToRight", "abc def", bidi.L, "abc def"},
+		{"RightToLeft", "אבג דהו", bidi.R, "והד גבא"},
+		{"RightToLeftInLeftToRight", "abc אבג דהו def", bidi.L, "abc והד גבא def"},
+		{"LeftToRightInRightToLeft", "אבג abc def דהו", bidi.R, "והד abc def גבא"},
+		{"NumbersInRightToLeft", "אבג 123", bidi.R, "123 גבא"},
+		{"NumbersAfterRightToLeft", "abc אבג 123", bidi.L, "abc 123 גבא"},
+		{"NeutralAtEdge", "abc אבג!", bidi.L, "abc גבא!"},
+		{"CombiningMarks", "אָב", bidi.R, "באָ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := clustersString(reorderLine(splitClusters(tt.line), tt.base))
+			if got != tt.visual {
+				t.Errorf("got %q want %q", got, tt.visual)
+			}
+		})
+	}
+}
+
+func TestParagraphDirection(t *testing.T) {
+	tests := []struct {
+		paragraph string
+		want      bidi.Class
+	}{
+		{"abc אבג", bidi.L},
+		{"123 אבג abc", bidi.R},
+		{"123 !?", bidi.L},
+		{"", bidi.L},
+	}
+
+	for _, tt := range tests {
+		if got := paragraphDirection(tt.paragraph); got != tt.want {
+			t.Errorf("expected %q to have direction %v but got %v", tt.paragraph, tt.want, got)
+		}
+	}
+}
+
+func TestWrapParagraph(t *testing.T) {
+	// Every glyph of the face is 7 dots wide so 5 fit on a line
+	width := fixed.I(5 * 7)
+
+	tests := []struct {
+		name      string
+		paragraph string
+		lines     []string
+	}{
+		{"Fits", "ab cd", []string{"ab cd"}},
+		{"Words", "ab cde f", []string{"ab", "cde f"}},
+		{"LongWord", "abcdefghijkl", []string{"abcde", "fghij", "kl"}},
+		{"LongWordAfterWord", "ab cdefgh", []string{"ab", "cdefg", "h"}},
+		{"Empty", "", []string{""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []string
+			for _, line := range wrapParagraph(basicfont.Face7x13, tt.paragraph, width) {
+				lines = append(lines, clustersString(line))
+			}
+			if strings.Join(lines, "|") != strings.Join(tt.lines, "|") {
+				t.Errorf("got %q want %q", lines, tt.lines)
+			}
+		})
+	}
+}
+
+func TestRenderTextLines(t *testing.T) {
+	f, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	one, err := RenderText("Hi", f, TextOptions{Size: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if one.Bounds().Dx() != DefaultPaperWidth {
+		t.Errorf("expected the default paper width but got %d", one.Bounds().Dx())
+	}
+
+	// Two paragraphs with the first wrapped onto two lines
+	three, err := RenderText("Hello there\nHi", f, TextOptions{Size: 24, Width: 80})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if three.Bounds().Dx() != 80 || three.Bounds().Dy() != 3*one.Bounds().Dy() {
+		t.Errorf("expected three lines 80 dots wide but got %v", three.Bounds())
+	}
+
+	_, err = RenderText("Hi", f, TextOptions{Size: 0})
+	var rangeErr *RangeError
+	if !errors.As(err, &rangeErr) || rangeErr.Field != "size" {
+		t.Errorf("expected a size range error but got %v", err)
+	}
+
+	_, err = RenderText("Hi", f, TextOptions{Size: 24, Width: -1})
+	if err == nil {
+		t.Error("expected a negative width to fail")
+	}
+}
@@ -0,0 +1,210 @@
+package hoin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/joeyak/hoin-printer/decoder"
+	"github.com/joeyak/hoin-printer/escpos"
+)
+
+// ErrInTransaction is returned when reading from the printer inside a
+// transaction, since nothing has been sent to answer yet
+var ErrInTransaction = errors.New("can't read from the printer inside a transaction")
+
+// flowMark is where bulk data waited on the flow control
+type flowMark struct {
+	offset, n int
+}
+
+// transaction collects the commands of a transaction instead of sending
+// them
+type transaction struct {
+	data  []byte
+	marks []flowMark
+}
+
+func (t *transaction) Write(b []byte) (int, error) {
+	t.data = append(t.data, b...)
+	return len(b), nil
+}
+
+func (t *transaction) Read(b []byte) (int, error) {
+	return 0, ErrInTransaction
+}
+
+// Wait marks where the real flow control waits once the commands are sent
+func (t *transaction) Wait(p Printer, n int) error {
+	t.marks = append(t.marks, flowMark{len(t.data), n})
+	return nil
+}
+
+// record runs fn with a printer that collects its commands
+func (p Printer) record(fn func(tx Printer) error) (*transaction, error) {
+	t := &transaction{}
+
+	tx := p
+	tx.dst = t
+	tx.flow = t
+	tx.buf = nil
+	tx.replies = newReplies()
+
+	err := fn(tx)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Transaction runs fn with a printer that collects its commands and sends
+// them all once fn returns nil.  When fn returns an error, like a bar code
+// that fails validation, nothing is sent.
+//
+// Status requests inside fn fail with ErrInTransaction.  Images and long
+// text are still paced by the flow control when they are sent, but pauses
+// like Morse timing happen while collecting and are lost.
+func (p Printer) Transaction(fn func(tx Printer) error) error {
+	errMsg := "could not run transaction: %w"
+
+	t, err := p.record(fn)
+	if err != nil {
+		return fmt.Errorf(errMsg, err)
+	}
+
+	start := 0
+	for _, mark := range t.marks {
+		_, err = p.Write(t.data[start:mark.offset])
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+
+		err = p.wait(mark.n)
+		if err != nil {
+			return fmt.Errorf(errMsg, err)
+		}
+		start = mark.offset
+	}
+
+	_, err = p.Write(t.data[start:])
+	if err != nil {
+		return fmt.Errorf(errMsg, err)
+	}
+	return nil
+}
+
+// DryRunReport is what a transaction would have done
+type DryRunReport struct {
+	// Bytes is how many bytes would be sent
+	Bytes int
+	// Paper is how much paper would be fed in millimeters
+	Paper float64
+	// Cuts is how many times the paper would be cut
+	Cuts int
+}
+
+// DryRun runs fn like Transaction but only reports what would be sent.
+// Paper use is worked out from the commands with the same layout as the
+// emulator, without drawing anything.
+func (p Printer) DryRun(fn func(tx Printer) error) (DryRunReport, error) {
+	t, err := p.record(fn)
+	if err != nil {
+		return DryRunReport{}, fmt.Errorf("could not run transaction: %w", err)
+	}
+
+	m := measure{Layout: escpos.NewLayout()}
+	for _, c := range decoder.Decode(t.data) {
+		m.command(c)
+	}
+
+	return DryRunReport{
+		Bytes: len(t.data),
+		Paper: float64(m.Fed) / DotsPerMM,
+		Cuts:  m.cuts,
+	}, nil
+}
+
+// measure follows the paper fed by a stream of commands on the layout the
+// emulator draws with
+type measure struct {
+	*escpos.Layout
+	cuts int
+}
+
+// param returns parameter i of c, or 0 if it is missing
+func param(c decoder.Command, i int) int {
+	if i < len(c.Params) {
+		return c.Params[i].Value
+	}
+	return 0
+}
+
+func (m *measure) command(c decoder.Command) {
+	if c.Error != "" {
+		return
+	}
+
+	switch c.Name {
+	case "TEXT":
+		m.text(c.Text)
+	case "LF":
+		m.Flush(m.LineSpacing)
+	case "HT":
+		m.Tab()
+	case "ESC @":
+		m.Reset()
+	case "ESC 2":
+		m.LineSpacing = escpos.DefaultLineSpacing
+	case "ESC 3":
+		m.LineSpacing = param(c, 0)
+	case "ESC J":
+		m.Flush(param(c, 0))
+	case "ESC d":
+		m.Flush(param(c, 0) * m.LineSpacing)
+	case "ESC M":
+		m.Font = param(c, 0) & 1
+	case "ESC V":
+		m.Rotate = param(c, 0)&1 == 1
+	case "ESC D":
+		m.Tabs = nil
+		for _, t := range c.Data {
+			m.Tabs = append(m.Tabs, int(t))
+		}
+	case "ESC \\":
+		m.Move(int(int16(uint16(param(c, 0)) | uint16(param(c, 1))<<8)))
+	case "ESC *":
+		height, dotWidth := 24, 1
+		if param(c, 0)%2 == 0 {
+			dotWidth = 2
+		}
+		m.Add((param(c, 1)|param(c, 2)<<8)*dotWidth, height)
+	case "GS h":
+		m.BarCodeHeight = param(c, 0)
+	case "GS H":
+		m.HRIPosition = escpos.HRIPosition(param(c, 0) % 4)
+	case "GS k":
+		m.Flush(0)
+		if m.HRIPosition == escpos.HRIAbove || m.HRIPosition == escpos.HRIBoth {
+			m.text(c.Text)
+			m.Flush(m.LineSpacing)
+		}
+		m.Add(0, m.BarCodeHeight)
+		m.Flush(0)
+		if m.HRIPosition == escpos.HRIBelow || m.HRIPosition == escpos.HRIBoth {
+			m.text(c.Text)
+			m.Flush(m.LineSpacing)
+		}
+	case "GS V":
+		if len(c.Params) > 1 {
+			m.Flush(param(c, 1))
+		}
+		m.Flush(0)
+		m.cuts++
+	}
+}
+
+// text adds characters to the line, wrapping at the paper width
+func (m *measure) text(text string) {
+	for i := 0; i < len(text); i++ {
+		m.Character()
+	}
+}
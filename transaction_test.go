@@ -0,0 +1,207 @@
+package hoin_test
+
+import (
+	"errors"
+	"image"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joeyak/hoin-printer"
+	"github.com/joeyak/hoin-printer/emulator"
+)
+
+func TestTransaction(t *testing.T) {
+	device := emulator.NewDevice()
+	printer := hoin.NewPrinter(device)
+
+	err := printer.Transaction(func(tx hoin.Printer) error {
+		err := tx.Println("Total")
+		if err != nil {
+			return err
+		}
+		if len(device.Bytes()) != 0 {
+			t.Errorf("expected nothing to be sent inside the transaction but got %q", device.Bytes())
+		}
+		return tx.Cut()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(device.Bytes()) != "Total\n\x1dV\x00" {
+		t.Errorf("expected the transaction to be sent but got %q", device.Bytes())
+	}
+}
+
+func TestTransactionInvalid(t *testing.T) {
+	device := emulator.NewDevice()
+	printer := hoin.NewPrinter(device)
+
+	err := printer.Transaction(func(tx hoin.Printer) error {
+		err := tx.Println("Total")
+		if err != nil {
+			return err
+		}
+		return tx.PrintBarCode(hoin.BcJAN13, "49012345678AB")
+	})
+	if err == nil {
+		t.Fatal("expected the bar code to fail")
+	}
+	if len(device.Bytes()) != 0 {
+		t.Errorf("expected nothing to be sent but got %q", device.Bytes())
+	}
+}
+
+func TestTransactionStatus(t *testing.T) {
+	device := emulator.NewDevice()
+	printer := hoin.NewPrinter(device)
+
+	err := printer.Transaction(func(tx hoin.Printer) error {
+		_, err := tx.TransmitErrorStatus()
+		return err
+	})
+	if !errors.Is(err, hoin.ErrInTransaction) {
+		t.Errorf("expected %q to be %q", err, hoin.ErrInTransaction)
+	}
+	if len(device.Bytes()) != 0 {
+		t.Errorf("expected nothing to be sent but got %q", device.Bytes())
+	}
+}
+
+func TestTransactionFlowControl(t *testing.T) {
+	throttle := emulator.ThrottleOptions{
+		BufferSize: 4096,
+		Speed:      100,
+		XonXoff:    true,
+	}
+	img := image.NewGray(image.Rect(0, 0, emulator.PaperWidth, 24*6))
+
+	flows := []struct {
+		name string
+		flow hoin.FlowControl
+	}{
+		{"StatusPolling", hoin.StatusPolling()},
+		{"XonXoff", hoin.XonXoff(time.Second)},
+	}
+
+	for _, tt := range flows {
+		t.Run(tt.name, func(t *testing.T) {
+			throttled := emulator.NewThrottled(emulator.New(), throttle)
+			printer := hoin.NewPrinter(throttled, hoin.WithFlowControl(tt.flow))
+
+			err := printer.Transaction(func(tx hoin.Printer) error {
+				return tx.PrintImage24(img, hoin.DoubleDensity)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stats := throttled.Stats()
+			if stats.Dropped > 0 {
+				t.Errorf("expected the image to be paced but %d bytes were dropped", stats.Dropped)
+			}
+		})
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	device := emulator.NewDevice()
+	printer := hoin.NewPrinter(device)
+
+	report, err := printer.DryRun(func(tx hoin.Printer) error {
+		err := tx.Println("Total")
+		if err != nil {
+			return err
+		}
+		err = tx.Feed(80)
+		if err != nil {
+			return err
+		}
+		err = tx.Cut()
+		if err != nil {
+			return err
+		}
+		err = tx.Println("Copy")
+		if err != nil {
+			return err
+		}
+		return tx.Cut()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(device.Bytes()) != 0 {
+		t.Errorf("expected nothing to be sent but got %q", device.Bytes())
+	}
+	if report.Bytes != 20 {
+		t.Errorf("expected 20 bytes but got %d", report.Bytes)
+	}
+	if report.Paper < 10 {
+		t.Errorf("expected at least 10mm of paper but got %.1fmm", report.Paper)
+	}
+	if report.Cuts != 2 {
+		t.Errorf("expected 2 cuts but got %d", report.Cuts)
+	}
+}
+
+func TestDryRunMatchesEmulator(t *testing.T) {
+	job := func(p hoin.Printer) error {
+		steps := []func() error{
+			func() error { return p.Println(strings.Repeat("wrapped ", 20)) },
+			func() error { return p.SetFont(hoin.FontB) },
+			func() error { return p.SetLineSpacing(40) },
+			func() error { return p.Println("Font B") },
+			func() error { return p.FeedLines(2) },
+			func() error { return p.SetRotate90(true) },
+			func() error { return p.Println("Sideways") },
+			func() error { return p.SetRotate90(false) },
+			func() error { return p.PrintImage8(image.NewGray(image.Rect(0, 0, 100, 40)), hoin.SingleDensity) },
+			func() error { return p.PrintImage24(image.NewGray(image.Rect(0, 0, 100, 50)), hoin.DoubleDensity) },
+			func() error { return p.SetHRIPosition(hoin.HRIBoth) },
+			func() error { return p.SetBarCodeHeight(80) },
+			func() error { return p.Print("Unfinished line") },
+			func() error { return p.PrintBarCode(hoin.BcJAN13, "4901234567894") },
+			func() error { return p.CutFeed(40) },
+			func() error { return p.Initialize() },
+			func() error { return p.Println("Copy") },
+			func() error { return p.Cut() },
+		}
+		for _, step := range steps {
+			err := step()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	virtual := emulator.New()
+	err := job(hoin.NewPrinter(virtual, hoin.WithFlowControl(hoin.NoFlowControl())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := hoin.NewPrinter(emulator.NewDevice()).DryRun(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := float64(virtual.Fed()) / hoin.DotsPerMM
+	if report.Paper != want {
+		t.Errorf("expected %.3fmm of paper like the emulator but got %.3fmm", want, report.Paper)
+	}
+
+	// The job ends with a cut so every dot fed is on a page
+	height := 0
+	for _, page := range virtual.Pages() {
+		height += page.Bounds().Dy()
+	}
+	if want := float64(height) / hoin.DotsPerMM; report.Paper != want {
+		t.Errorf("expected %.3fmm of paper like the emulated pages but got %.3fmm", want, report.Paper)
+	}
+	if report.Cuts != 2 {
+		t.Errorf("expected 2 cuts but got %d", report.Cuts)
+	}
+}
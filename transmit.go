@@ -1,22 +1,38 @@
 package hoin
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/joeyak/hoin-printer/escpos"
+)
+
+type (
+	PrinterStatus     = escpos.PrinterStatus
+	OfflineStatus     = escpos.OfflineStatus
+	ErrorStatus       = escpos.ErrorStatus
+	PaperSensorStatus = escpos.PaperSensorStatus
+	PrinterIDType     = escpos.PrinterIDType
+)
+
+const (
+	IDModel        = escpos.IDModel
+	IDType         = escpos.IDType
+	IDROMVersion   = escpos.IDROMVersion
+	IDFirmware     = escpos.IDFirmware
+	IDManufacturer = escpos.IDManufacturer
+	IDModelName    = escpos.IDModelName
+	IDSerialNumber = escpos.IDSerialNumber
+)
 
 func (p Printer) realTimeStatusTransmission(n int) (byte, error) {
 	errMsg := "could not transmit real-time status: %w"
 
-	err := checkRange(n, 1, 4, "n status type")
-	if err != nil {
-		return 0, fmt.Errorf(errMsg, err)
-	}
-
-	_, err = p.Write([]byte{DLE, 0x04, byte(n)})
-	if err != nil {
-		return 0, fmt.Errorf(errMsg, err)
+	// Don't leave a status request in the printer that can't be answered
+	if _, ok := p.dst.(writeOnly); ok {
+		return 0, fmt.Errorf(errMsg, ErrWriteOnly)
 	}
 
-	b := make([]byte, 1)
-	_, err = p.Read(b)
+	b, err := p.request(escpos.AppendStatusRequest(nil, n))
 	if err != nil {
 		return 0, fmt.Errorf(errMsg, err)
 	}
@@ -24,23 +40,12 @@ func (p Printer) realTimeStatusTransmission(n int) (byte, error) {
 	return b[0], nil
 }
 
-type PrinterStatus struct {
-	DrawerOpen bool
-}
-
 func (p Printer) TransmitPrinterStatus() (PrinterStatus, error) {
 	b, err := p.realTimeStatusTransmission(1)
 	if err != nil {
 		return PrinterStatus{}, fmt.Errorf("could not transmit printer status: %w", err)
 	}
-
-	return PrinterStatus{
-		DrawerOpen: b&0b0100 == 0b0100,
-	}, nil
-}
-
-type OfflineStatus struct {
-	CoverOpen, FeedButton, PrintingStopped, ErrorOccured bool
+	return escpos.ParsePrinterStatus(b), nil
 }
 
 func (p Printer) TransmitOfflineStatus() (OfflineStatus, error) {
@@ -48,17 +53,7 @@ func (p Printer) TransmitOfflineStatus() (OfflineStatus, error) {
 	if err != nil {
 		return OfflineStatus{}, fmt.Errorf("could not transmit offline status: %w", err)
 	}
-
-	return OfflineStatus{
-		CoverOpen:       b&0b0000_0100 == 0b0000_0100,
-		FeedButton:      b&0b0000_1000 == 0b0000_1000,
-		PrintingStopped: b&0b0010_0000 == 0b0010_0000,
-		ErrorOccured:    b&0b0100_0000 == 0b0100_0000,
-	}, nil
-}
-
-type ErrorStatus struct {
-	AutoCutter, UnRecoverable, AutoRecoverable bool
+	return escpos.ParseOfflineStatus(b), nil
 }
 
 func (p Printer) TransmitErrorStatus() (ErrorStatus, error) {
@@ -66,16 +61,7 @@ func (p Printer) TransmitErrorStatus() (ErrorStatus, error) {
 	if err != nil {
 		return ErrorStatus{}, fmt.Errorf("could not transmit error status: %w", err)
 	}
-
-	return ErrorStatus{
-		AutoCutter:      b&0b0000_1000 == 0b0000_1000,
-		UnRecoverable:   b&0b0010_0000 == 0b0010_0000,
-		AutoRecoverable: b&0b0100_0000 == 0b0100_0000,
-	}, nil
-}
-
-type PaperSensorStatus struct {
-	NearEnd, RollEnd bool
+	return escpos.ParseErrorStatus(b), nil
 }
 
 func (p Printer) TransmitPaperSensorStatus() (PaperSensorStatus, error) {
@@ -83,9 +69,52 @@ func (p Printer) TransmitPaperSensorStatus() (PaperSensorStatus, error) {
 	if err != nil {
 		return PaperSensorStatus{}, fmt.Errorf("could not transmit error status: %w", err)
 	}
+	return escpos.ParsePaperSensorStatus(b), nil
+}
 
-	return PaperSensorStatus{
-		NearEnd: b&0b0000_1100 == 0b0000_1100,
-		RollEnd: b&0b0110_0000 == 0b0110_0000,
-	}, nil
+// checkOffline asks the printer for its offline status, returning an error
+// matching ErrCoverOpen or ErrPaperOut if it stopped printing
+func (p Printer) checkOffline() error {
+	offline, err := p.TransmitOfflineStatus()
+	if err != nil {
+		return err
+	}
+	return offline.Err()
+}
+
+// Ready asks the printer whether it can print, returning an error matching
+// ErrCoverOpen or ErrPaperOut if it can't
+func (p Printer) Ready() error {
+	errMsg := "printer is not ready: %w"
+
+	err := p.checkOffline()
+	if err != nil {
+		return fmt.Errorf(errMsg, err)
+	}
+
+	paper, err := p.TransmitPaperSensorStatus()
+	if err != nil {
+		return fmt.Errorf(errMsg, err)
+	}
+	err = paper.Err()
+	if err != nil {
+		return fmt.Errorf(errMsg, err)
+	}
+	return nil
+}
+
+// TransmitPrinterID asks the printer for ID n.  Model, type and ROM version
+// IDs are one byte, the others are text without the header and NUL.
+func (p Printer) TransmitPrinterID(n PrinterIDType) ([]byte, error) {
+	errMsg := "could not transmit printer ID: %w"
+
+	if _, ok := p.dst.(writeOnly); ok {
+		return nil, fmt.Errorf(errMsg, ErrWriteOnly)
+	}
+
+	id, err := p.request(escpos.AppendPrinterIDRequest(nil, n))
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+	return id, nil
 }
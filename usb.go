@@ -0,0 +1,164 @@
+package hoin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrWriteOnly is returned when reading from a printer that can only be
+// written to, like a unidirectional USB printer
+var ErrWriteOnly = errors.New("printer can not be read from")
+
+// USBDevice is a USB printer-class device found in sysfs
+type USBDevice struct {
+	// Path is the device node, like /dev/usb/lp0
+	Path string
+	// ID is the IEEE 1284 device ID reported by the printer
+	ID           string
+	Manufacturer string
+	Model        string
+	CommandSet   string
+	// Bidirectional is true when the printer can send status replies
+	Bidirectional bool
+}
+
+// ESCPOS reports whether the device looks like a HOIN or other ESC/POS
+// printer
+func (d USBDevice) ESCPOS() bool {
+	return strings.Contains(strings.ToUpper(d.CommandSet), "ESC/POS") ||
+		strings.EqualFold(d.Manufacturer, "HOIN")
+}
+
+// parseDeviceID splits an IEEE 1284 device ID like
+// "MFG:HOIN;CMD:ESC/POS;MDL:HOP-E802;" into its keys and values
+func parseDeviceID(id string) map[string]string {
+	fields := map[string]string{}
+	for _, field := range strings.Split(id, ";") {
+		key, value, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		fields[strings.ToUpper(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+	return fields
+}
+
+// firstOf returns the value of the first key that is set
+func firstOf(fields map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := fields[key]; ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// FindUSBPrinters lists the USB printers the usblp driver knows about by
+// reading sysfs under root, which is / on a real system.  Device paths are
+// under root too.
+func FindUSBPrinters(root string) ([]USBDevice, error) {
+	errMsg := "could not find usb printers: %w"
+
+	matches, err := filepath.Glob(filepath.Join(root, "sys", "class", "usbmisc", "lp*"))
+	if err != nil {
+		return nil, fmt.Errorf(errMsg, err)
+	}
+	sort.Strings(matches)
+
+	var devices []USBDevice
+	for _, match := range matches {
+		id, err := os.ReadFile(filepath.Join(match, "device", "ieee1284_id"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf(errMsg, err)
+		}
+
+		fields := parseDeviceID(string(id))
+		device := USBDevice{
+			Path:         filepath.Join(root, "dev", "usb", filepath.Base(match)),
+			ID:           strings.TrimSpace(string(id)),
+			Manufacturer: firstOf(fields, "MFG", "MANUFACTURER"),
+			Model:        firstOf(fields, "MDL", "MODEL"),
+			CommandSet:   firstOf(fields, "CMD", "COMMAND SET"),
+		}
+
+		// 1 is unidirectional, 2 is bidirectional and 3 is IEEE 1284.4
+		protocol, err := os.ReadFile(filepath.Join(match, "device", "bInterfaceProtocol"))
+		if err == nil {
+			p := strings.TrimSpace(string(protocol))
+			device.Bidirectional = p == "02" || p == "03"
+		}
+
+		devices = append(devices, device)
+	}
+
+	return devices, nil
+}
+
+// writeOnly fails reads straight away instead of blocking forever
+type writeOnly struct {
+	*os.File
+}
+
+func (writeOnly) Read(b []byte) (int, error) {
+	return 0, ErrWriteOnly
+}
+
+// Open opens the device as a Printer
+//
+// Reading from a unidirectional device fails with ErrWriteOnly, so status
+// functions return an error instead of hanging.  Since status polling can't
+// work on them, images and long text default to FixedDelay flow control.
+func (d USBDevice) Open(opts ...Option) (Printer, error) {
+	if d.Bidirectional {
+		file, err := os.OpenFile(d.Path, os.O_RDWR, 0)
+		if err != nil {
+			return Printer{}, fmt.Errorf("unable to open device: %w", err)
+		}
+		return NewPrinter(file, opts...), nil
+	}
+
+	file, err := os.OpenFile(d.Path, os.O_WRONLY, 0)
+	if err != nil {
+		return Printer{}, fmt.Errorf("unable to open device: %w", err)
+	}
+
+	opts = append([]Option{WithFlowControl(FixedDelay(35 * time.Millisecond))}, opts...)
+	return NewPrinter(writeOnly{file}, opts...), nil
+}
+
+// NewDevicePrinter opens a USB printer like /dev/usb/lp0
+//
+// When path is empty the first ESC/POS printer found by FindUSBPrinters is
+// used.  Devices that sysfs doesn't describe are assumed to be readable.
+func NewDevicePrinter(path string, opts ...Option) (Printer, error) {
+	errMsg := "could not open device printer: %w"
+
+	devices, err := FindUSBPrinters("/")
+	if err != nil {
+		return Printer{}, fmt.Errorf(errMsg, err)
+	}
+
+	if path == "" {
+		for _, device := range devices {
+			if device.ESCPOS() {
+				return device.Open(opts...)
+			}
+		}
+		return Printer{}, fmt.Errorf(errMsg, errors.New("no ESC/POS usb printers were found"))
+	}
+
+	for _, device := range devices {
+		if device.Path == filepath.Clean(path) {
+			return device.Open(opts...)
+		}
+	}
+	return USBDevice{Path: path, Bidirectional: true}.Open(opts...)
+}
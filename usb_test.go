@@ -0,0 +1,121 @@
+package hoin_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joeyak/hoin-printer"
+)
+
+// fakeSysfs creates a sysfs tree and device nodes for USB printers under a
+// temporary root.  Devices without an ID are left without ieee1284_id.
+func fakeSysfs(t *testing.T, devices map[string][2]string) string {
+	root := t.TempDir()
+
+	for name, device := range devices {
+		dir := filepath.Join(root, "sys", "class", "usbmisc", name, "device")
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		id, protocol := device[0], device[1]
+		if id != "" {
+			err = os.WriteFile(filepath.Join(dir, "ieee1284_id"), []byte(id+"\n"), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err = os.WriteFile(filepath.Join(dir, "bInterfaceProtocol"), []byte(protocol+"\n"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.MkdirAll(filepath.Join(root, "dev", "usb"), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(root, "dev", "usb", name), nil, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func TestFindUSBPrinters(t *testing.T) {
+	root := fakeSysfs(t, map[string][2]string{
+		"lp0": {"MFG:HOIN;CMD:ESC/POS;MDL:HOP-E802;CLS:PRINTER;", "01"},
+		"lp1": {"MANUFACTURER:EPSON;COMMAND SET:ESC/P;MODEL:LX-350;", "02"},
+		"lp2": {"", "02"},
+	})
+
+	devices, err := hoin.FindUSBPrinters(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 2 {
+		t.Fatalf("expected 2 printers with device IDs but got %d", len(devices))
+	}
+
+	hoinPrinter, epson := devices[0], devices[1]
+	want := hoin.USBDevice{
+		Path:          filepath.Join(root, "dev", "usb", "lp0"),
+		ID:            "MFG:HOIN;CMD:ESC/POS;MDL:HOP-E802;CLS:PRINTER;",
+		Manufacturer:  "HOIN",
+		Model:         "HOP-E802",
+		CommandSet:    "ESC/POS",
+		Bidirectional: false,
+	}
+	if hoinPrinter != want {
+		t.Errorf("got %+v want %+v", hoinPrinter, want)
+	}
+	if !hoinPrinter.ESCPOS() {
+		t.Error("HOIN printer was not detected as ESC/POS")
+	}
+
+	if epson.Manufacturer != "EPSON" || epson.Model != "LX-350" || !epson.Bidirectional {
+		t.Errorf("long device ID keys were not read: %+v", epson)
+	}
+	if epson.ESCPOS() {
+		t.Error("ESC/P printer was detected as ESC/POS")
+	}
+}
+
+func TestUSBWriteOnly(t *testing.T) {
+	root := fakeSysfs(t, map[string][2]string{
+		"lp0": {"MFG:HOIN;CMD:ESC/POS;", "01"},
+	})
+
+	devices, err := hoin.FindUSBPrinters(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	printer, err := devices[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer printer.Close()
+
+	err = printer.Println("Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = printer.TransmitErrorStatus()
+	if !errors.Is(err, hoin.ErrWriteOnly) {
+		t.Errorf("expected status on a write only device to fail with %q but got %v", hoin.ErrWriteOnly, err)
+	}
+
+	written, err := os.ReadFile(devices[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(written) != "Hello\n" {
+		t.Errorf("unexpected bytes written to device: %q", written)
+	}
+}